@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"interpreter/token"
 	"strings"
 )
@@ -9,6 +10,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // ノードの開始位置
+	End() token.Position // ノードの直後の位置
 }
 
 type Statement interface {
@@ -24,6 +27,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // ソース中のコメント、字句解析器がコメントを返すときだけ設定される
 }
 
 /*
@@ -45,6 +49,20 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+	return token.Position{}
+}
+
 // Identifier
 
 /*
@@ -69,6 +87,9 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
+
 // LetStatement
 
 /*
@@ -86,6 +107,18 @@ func (s *LetStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *LetStatement) Pos() token.Position { return s.Token.Pos }
+
+func (s *LetStatement) End() token.Position {
+	if s.Value != nil {
+		return s.Value.End()
+	}
+	if s.Name != nil {
+		return s.Name.End()
+	}
+	return s.Token.End
+}
+
 func (s *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(s.TokenLiteral() + " ")
@@ -115,6 +148,15 @@ func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
 
+func (r *ReturnStatement) Pos() token.Position { return r.Token.Pos }
+
+func (r *ReturnStatement) End() token.Position {
+	if r.ReturnValue != nil {
+		return r.ReturnValue.End()
+	}
+	return r.Token.End
+}
+
 func (r *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(r.TokenLiteral() + " ")
@@ -138,6 +180,20 @@ type ExpressionStatement struct {
 
 func (e *ExpressionStatement) statementNode() {}
 
+func (e *ExpressionStatement) Pos() token.Position {
+	if e.Expression != nil {
+		return e.Expression.Pos()
+	}
+	return e.Token.Pos
+}
+
+func (e *ExpressionStatement) End() token.Position {
+	if e.Expression != nil {
+		return e.Expression.End()
+	}
+	return e.Token.End
+}
+
 func (e *ExpressionStatement) String() string {
 	if e.Expression != nil {
 		return e.Expression.String()
@@ -162,10 +218,91 @@ func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *IntegerLiteral) Pos() token.Position { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Position { return i.Token.End }
+
 func (i *IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+// FloatLiteral
+
+/*
+FloatLiteral
+浮動小数点数リテラルの型
+*/
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) expressionNode() {}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FloatLiteral) Pos() token.Position { return f.Token.Pos }
+func (f *FloatLiteral) End() token.Position { return f.Token.End }
+
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
+// StringLiteral
+
+/*
+StringLiteral
+文字列リテラルの型
+Valueはエスケープシーケンスを展開した後の値
+*/
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (s *StringLiteral) expressionNode() {}
+
+func (s *StringLiteral) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *StringLiteral) String() string {
+	return quote(s.Value)
+}
+
+func (s *StringLiteral) Pos() token.Position { return s.Token.Pos }
+func (s *StringLiteral) End() token.Position { return s.Token.End }
+
+/*
+quote
+文字列をダブルクォートで囲み、字句解析器が展開するエスケープシーケンスに戻す
+*/
+func quote(s string) string {
+	var out bytes.Buffer
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				out.WriteString(fmt.Sprintf(`\u{%x}`, r))
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
 // PrefixExpression
 
 /*
@@ -184,6 +321,15 @@ func (p *PrefixExpression) TokenLiteral() string {
 	return p.Token.Literal
 }
 
+func (p *PrefixExpression) Pos() token.Position { return p.Token.Pos }
+
+func (p *PrefixExpression) End() token.Position {
+	if p.Right != nil {
+		return p.Right.End()
+	}
+	return p.Token.End
+}
+
 func (p *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -206,6 +352,20 @@ func (i *InfixExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *InfixExpression) Pos() token.Position {
+	if i.Left != nil {
+		return i.Left.Pos()
+	}
+	return i.Token.Pos
+}
+
+func (i *InfixExpression) End() token.Position {
+	if i.Right != nil {
+		return i.Right.End()
+	}
+	return i.Token.End
+}
+
 func (i *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -216,6 +376,90 @@ func (i *InfixExpression) String() string {
 	return out.String()
 }
 
+/*
+LogicalExpression
+論理演算子"&&"と"||"の式の型
+左辺の値だけで結果が決まるときは右辺を評価しないため、InfixExpressionとは区別する
+*/
+type LogicalExpression struct {
+	Token    token.Token // 演算子のトークン
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (l *LogicalExpression) expressionNode() {}
+
+func (l *LogicalExpression) TokenLiteral() string {
+	return l.Token.Literal
+}
+
+func (l *LogicalExpression) Pos() token.Position {
+	if l.Left != nil {
+		return l.Left.Pos()
+	}
+	return l.Token.Pos
+}
+
+func (l *LogicalExpression) End() token.Position {
+	if l.Right != nil {
+		return l.Right.End()
+	}
+	return l.Token.End
+}
+
+func (l *LogicalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(l.Left.String())
+	out.WriteString(" " + l.Operator + " ")
+	out.WriteString(l.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+/*
+AssignExpression
+代入式の型
+Targetは識別子か添字式で、Operatorは"="か"+="などの複合代入演算子
+*/
+type AssignExpression struct {
+	Token    token.Token // 代入演算子のトークン
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (a *AssignExpression) expressionNode() {}
+
+func (a *AssignExpression) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *AssignExpression) Pos() token.Position {
+	if a.Target != nil {
+		return a.Target.Pos()
+	}
+	return a.Token.Pos
+}
+
+func (a *AssignExpression) End() token.Position {
+	if a.Value != nil {
+		return a.Value.End()
+	}
+	return a.Token.End
+}
+
+func (a *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(a.Target.String())
+	out.WriteString(" " + a.Operator + " ")
+	out.WriteString(a.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 // Boolean
 
 type Boolean struct {
@@ -229,6 +473,9 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) End() token.Position { return b.Token.End }
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -238,8 +485,9 @@ BlockStatement
 ブロック文の型
 */
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // '{' トークン
 	Statements []Statement
+	RBrace     token.Token // '}' トークン
 }
 
 func (b *BlockStatement) statementNode() {}
@@ -248,6 +496,18 @@ func (b *BlockStatement) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *BlockStatement) Pos() token.Position { return b.Token.Pos }
+
+func (b *BlockStatement) End() token.Position {
+	if b.RBrace.End.IsValid() {
+		return b.RBrace.End
+	}
+	if n := len(b.Statements); n > 0 {
+		return b.Statements[n-1].End()
+	}
+	return b.Token.End
+}
+
 func (b *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range b.Statements {
@@ -273,6 +533,18 @@ func (e *IfExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *IfExpression) Pos() token.Position { return e.Token.Pos }
+
+func (e *IfExpression) End() token.Position {
+	if e.Alternative != nil {
+		return e.Alternative.End()
+	}
+	if e.Consequence != nil {
+		return e.Consequence.End()
+	}
+	return e.Token.End
+}
+
 func (e *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -286,6 +558,127 @@ func (e *IfExpression) String() string {
 	return out.String()
 }
 
+/*
+WhileStatement
+while文の型
+条件が真の間、本体を繰り返し実行する
+*/
+type WhileStatement struct {
+	Token     token.Token // 'while' トークン
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (w *WhileStatement) statementNode() {}
+
+func (w *WhileStatement) TokenLiteral() string {
+	return w.Token.Literal
+}
+
+func (w *WhileStatement) Pos() token.Position { return w.Token.Pos }
+
+func (w *WhileStatement) End() token.Position {
+	if w.Body != nil {
+		return w.Body.End()
+	}
+	if w.Condition != nil {
+		return w.Condition.End()
+	}
+	return w.Token.End
+}
+
+func (w *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while (")
+	out.WriteString(w.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(w.Body.String())
+	return out.String()
+}
+
+/*
+ForInStatement
+for-in文の型
+Iterableの要素を一つずつVariableに束縛して本体を実行する
+*/
+type ForInStatement struct {
+	Token    token.Token // 'for' トークン
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (f *ForInStatement) statementNode() {}
+
+func (f *ForInStatement) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *ForInStatement) Pos() token.Position { return f.Token.Pos }
+
+func (f *ForInStatement) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	if f.Iterable != nil {
+		return f.Iterable.End()
+	}
+	return f.Token.End
+}
+
+func (f *ForInStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	out.WriteString(f.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+/*
+BreakStatement
+break文の型
+*/
+type BreakStatement struct {
+	Token token.Token // 'break' トークン
+}
+
+func (b *BreakStatement) statementNode() {}
+
+func (b *BreakStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BreakStatement) Pos() token.Position { return b.Token.Pos }
+func (b *BreakStatement) End() token.Position { return b.Token.End }
+
+func (b *BreakStatement) String() string {
+	return b.Token.Literal + ";"
+}
+
+/*
+ContinueStatement
+continue文の型
+*/
+type ContinueStatement struct {
+	Token token.Token // 'continue' トークン
+}
+
+func (c *ContinueStatement) statementNode() {}
+
+func (c *ContinueStatement) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *ContinueStatement) Pos() token.Position { return c.Token.Pos }
+func (c *ContinueStatement) End() token.Position { return c.Token.End }
+
+func (c *ContinueStatement) String() string {
+	return c.Token.Literal + ";"
+}
+
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
@@ -298,6 +691,15 @@ func (f *FunctionLiteral) TokenLiteral() string {
 	return f.Token.Literal
 }
 
+func (f *FunctionLiteral) Pos() token.Position { return f.Token.Pos }
+
+func (f *FunctionLiteral) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	return f.Token.End
+}
+
 func (f *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	var params []string
@@ -312,14 +714,149 @@ func (f *FunctionLiteral) String() string {
 	return out.String()
 }
 
+/*
+ArrayLiteral
+配列リテラルの型
+*/
+type ArrayLiteral struct {
+	Token    token.Token // '[' トークン
+	Elements []Expression
+	RBracket token.Token // ']' トークン
+}
+
+func (a *ArrayLiteral) expressionNode() {}
+
+func (a *ArrayLiteral) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	var elements []string
+	for _, el := range a.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+func (a *ArrayLiteral) Pos() token.Position { return a.Token.Pos }
+
+func (a *ArrayLiteral) End() token.Position {
+	if a.RBracket.End.IsValid() {
+		return a.RBracket.End
+	}
+	if n := len(a.Elements); n > 0 && a.Elements[n-1] != nil {
+		return a.Elements[n-1].End()
+	}
+	return a.Token.End
+}
+
+/*
+IndexExpression
+添字式の型
+*/
+type IndexExpression struct {
+	Token    token.Token // '[' トークン
+	Left     Expression
+	Index    Expression
+	RBracket token.Token // ']' トークン
+}
+
+func (i *IndexExpression) expressionNode() {}
+
+func (i *IndexExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString("[")
+	out.WriteString(i.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+func (i *IndexExpression) Pos() token.Position {
+	if i.Left != nil {
+		return i.Left.Pos()
+	}
+	return i.Token.Pos
+}
+
+func (i *IndexExpression) End() token.Position {
+	if i.RBracket.End.IsValid() {
+		return i.RBracket.End
+	}
+	if i.Index != nil {
+		return i.Index.End()
+	}
+	return i.Token.End
+}
+
+/*
+HashLiteral
+ハッシュリテラルの型
+Pairsはソースに書かれた順に並ぶ
+*/
+type HashLiteral struct {
+	Token  token.Token // '{' トークン
+	Pairs  []*HashPair
+	RBrace token.Token // '}' トークン
+}
+
+/*
+HashPair
+ハッシュリテラルのキーと値の組
+*/
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (h *HashLiteral) expressionNode() {}
+
+func (h *HashLiteral) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+func (h *HashLiteral) String() string {
+	var out bytes.Buffer
+	var pairs []string
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+func (h *HashLiteral) Pos() token.Position { return h.Token.Pos }
+
+func (h *HashLiteral) End() token.Position {
+	if h.RBrace.End.IsValid() {
+		return h.RBrace.End
+	}
+	if n := len(h.Pairs); n > 0 && h.Pairs[n-1].Value != nil {
+		return h.Pairs[n-1].Value.End()
+	}
+	return h.Token.End
+}
+
 /*
 CallExpression
 呼び出し式
 */
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // '(' トークン
 	Function  Expression
 	Arguments []Expression
+	RParen    token.Token // ')' トークン
 }
 
 func (e *CallExpression) expressionNode() {}
@@ -328,6 +865,23 @@ func (e *CallExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *CallExpression) Pos() token.Position {
+	if e.Function != nil {
+		return e.Function.Pos()
+	}
+	return e.Token.Pos
+}
+
+func (e *CallExpression) End() token.Position {
+	if e.RParen.End.IsValid() {
+		return e.RParen.End
+	}
+	if n := len(e.Arguments); n > 0 && e.Arguments[n-1] != nil {
+		return e.Arguments[n-1].End()
+	}
+	return e.Token.End
+}
+
 func (e *CallExpression) String() string {
 	var out bytes.Buffer
 	var args []string
@@ -340,3 +894,121 @@ func (e *CallExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+/*
+GroupedExpression
+括弧で囲まれた式
+評価には影響しないが、ソースに括弧が書かれていたことを残すために使う
+*/
+type GroupedExpression struct {
+	Token      token.Token // '(' トークン
+	Expression Expression
+	RParen     token.Token // ')' トークン
+}
+
+func (g *GroupedExpression) expressionNode() {}
+
+func (g *GroupedExpression) TokenLiteral() string {
+	return g.Token.Literal
+}
+
+/*
+String
+中の式をそのまま返す
+中置式などはもともと括弧つきで表示されるので、括弧を重ねない
+*/
+func (g *GroupedExpression) String() string {
+	return g.Expression.String()
+}
+
+func (g *GroupedExpression) Pos() token.Position { return g.Token.Pos }
+
+func (g *GroupedExpression) End() token.Position {
+	if g.RParen.End.IsValid() {
+		return g.RParen.End
+	}
+	if g.Expression != nil {
+		return g.Expression.End()
+	}
+	return g.Token.End
+}
+
+/*
+Unparen
+式を囲む括弧をすべて取り除いた式を返す
+*/
+func Unparen(e Expression) Expression {
+	for {
+		g, ok := e.(*GroupedExpression)
+		if !ok {
+			return e
+		}
+		e = g.Expression
+	}
+}
+
+/*
+Comment
+コメントの型
+Tokenのリテラルは区切り記号を含むコメント全体
+*/
+type Comment struct {
+	Token token.Token
+}
+
+func (c *Comment) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *Comment) String() string {
+	return c.Token.Literal
+}
+
+func (c *Comment) Pos() token.Position { return c.Token.Pos }
+func (c *Comment) End() token.Position { return c.Token.End }
+
+/*
+BadStatement
+構文エラーで解析できなかった文の代わりに置かれるノード
+*/
+type BadStatement struct {
+	Token token.Token // 文の最初のトークン
+	From  token.Position
+	To    token.Position
+}
+
+func (b *BadStatement) statementNode() {}
+
+func (b *BadStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BadStatement) String() string {
+	return "<bad statement>"
+}
+
+func (b *BadStatement) Pos() token.Position { return b.From }
+func (b *BadStatement) End() token.Position { return b.To }
+
+/*
+BadExpression
+構文エラーで解析できなかった式の代わりに置かれるノード
+*/
+type BadExpression struct {
+	Token token.Token // 式の最初のトークン
+	From  token.Position
+	To    token.Position
+}
+
+func (b *BadExpression) expressionNode() {}
+
+func (b *BadExpression) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BadExpression) String() string {
+	return "<bad expression>"
+}
+
+func (b *BadExpression) Pos() token.Position { return b.From }
+func (b *BadExpression) End() token.Position { return b.To }
@@ -0,0 +1,158 @@
+package ast
+
+import "fmt"
+
+/*
+ModifierFunc
+ノードを受け取り、置き換え後のノードを返す
+置き換えないときは受け取ったノードをそのまま返す
+*/
+type ModifierFunc func(Node) Node
+
+/*
+Modify
+子から先に書き換え、最後にnode自身をmodifierに渡す
+ノードは新しく作らずにその場で書き換える
+文の並びの中でmodifierがnilを返した文は並びから取り除く
+
+式を置く場所に式でないノードが返された場合など、
+置き換え後のノードがその場所に置けない型であればpanicする
+*/
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		if n.Name != nil {
+			n.Name = modifyIdentifier(n.Name, modifier)
+		}
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean, *BadStatement, *BadExpression, *Comment,
+		*BreakStatement, *ContinueStatement:
+		// 子はない
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *LogicalExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *AssignExpression:
+		n.Target = modifyExpression(n.Target, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *GroupedExpression:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		if n.Consequence != nil {
+			n.Consequence = modifyBlock(n.Consequence, modifier)
+		}
+		if n.Alternative != nil {
+			n.Alternative = modifyBlock(n.Alternative, modifier)
+		}
+	case *WhileStatement:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		if n.Body != nil {
+			n.Body = modifyBlock(n.Body, modifier)
+		}
+	case *ForInStatement:
+		if n.Variable != nil {
+			n.Variable = modifyIdentifier(n.Variable, modifier)
+		}
+		n.Iterable = modifyExpression(n.Iterable, modifier)
+		if n.Body != nil {
+			n.Body = modifyBlock(n.Body, modifier)
+		}
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			if p != nil {
+				n.Parameters[i] = modifyIdentifier(p, modifier)
+			}
+		}
+		if n.Body != nil {
+			n.Body = modifyBlock(n.Body, modifier)
+		}
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			pair.Key = modifyExpression(pair.Key, modifier)
+			pair.Value = modifyExpression(pair.Value, modifier)
+		}
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+	return modifier(node)
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+	modified := Modify(e, modifier)
+	if modified == nil {
+		return nil
+	}
+	exp, ok := modified.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: %T is not an expression", modified))
+	}
+	return exp
+}
+
+func modifyExpressions(list []Expression, modifier ModifierFunc) {
+	for i, e := range list {
+		list[i] = modifyExpression(e, modifier)
+	}
+}
+
+func modifyStatements(list []Statement, modifier ModifierFunc) []Statement {
+	out := list[:0]
+	for _, s := range list {
+		if s == nil {
+			out = append(out, s)
+			continue
+		}
+		modified := Modify(s, modifier)
+		if modified == nil {
+			continue
+		}
+		stmt, ok := modified.(Statement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Modify: %T is not a statement", modified))
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+func modifyIdentifier(i *Identifier, modifier ModifierFunc) *Identifier {
+	modified := Modify(i, modifier)
+	ident, ok := modified.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: %T is not an identifier", modified))
+	}
+	return ident
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	modified := Modify(b, modifier)
+	block, ok := modified.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: %T is not a block", modified))
+	}
+	return block
+}
@@ -0,0 +1,204 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		if integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&GroupedExpression{Expression: one()},
+			&GroupedExpression{Expression: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), two(), one()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&HashLiteral{Pairs: []*HashPair{{Key: one(), Value: one()}, {Key: one(), Value: one()}}},
+			&HashLiteral{Pairs: []*HashPair{{Key: two(), Value: two()}, {Key: two(), Value: two()}}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+func TestModifyReplacesNodes(t *testing.T) {
+	renameX := func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &Identifier{Value: "y"}
+		}
+		return node
+	}
+	fn := &FunctionLiteral{
+		Parameters: []*Identifier{{Value: "x"}, {Value: "z"}},
+		Body: &BlockStatement{
+			Statements: []Statement{&ExpressionStatement{Expression: &Identifier{Value: "x"}}},
+		},
+	}
+	Modify(fn, renameX)
+	if fn.Parameters[0].Value != "y" || fn.Parameters[1].Value != "z" {
+		t.Errorf("parameters not modified. got=%s, %s", fn.Parameters[0], fn.Parameters[1])
+	}
+	if fn.Body.String() != "y" {
+		t.Errorf("body not modified. got=%q", fn.Body.String())
+	}
+}
+
+func TestModifyIsBottomUp(t *testing.T) {
+	// 子が先に書き換えられるので、1 + 1 を畳み込む前に 1 が 2 になっている
+	exp := &InfixExpression{Left: &IntegerLiteral{Value: 1}, Operator: "+", Right: &IntegerLiteral{Value: 1}}
+	var order []string
+	modified := Modify(exp, func(node Node) Node {
+		switch node := node.(type) {
+		case *IntegerLiteral:
+			order = append(order, "int")
+			return &IntegerLiteral{Value: node.Value * 2}
+		case *InfixExpression:
+			order = append(order, "infix")
+			left := node.Left.(*IntegerLiteral)
+			right := node.Right.(*IntegerLiteral)
+			return &IntegerLiteral{Value: left.Value + right.Value}
+		}
+		return node
+	})
+	if !reflect.DeepEqual(order, []string{"int", "int", "infix"}) {
+		t.Errorf("wrong order. got=%v", order)
+	}
+	if lit, ok := modified.(*IntegerLiteral); !ok || lit.Value != 4 {
+		t.Errorf("wrong result. got=%#v", modified)
+	}
+}
+
+func TestModifyPanicsOnWrongType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	stmt := &ExpressionStatement{Expression: &IntegerLiteral{Value: 1}}
+	Modify(stmt, func(node Node) Node {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return &BlockStatement{}
+		}
+		return node
+	})
+}
+
+func TestModifyRemovesNilStatements(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &IntegerLiteral{Value: 1}},
+		&ExpressionStatement{Expression: &Identifier{Value: "drop"}},
+		&BlockStatement{Statements: []Statement{
+			&ExpressionStatement{Expression: &Identifier{Value: "drop"}},
+			&ExpressionStatement{Expression: &IntegerLiteral{Value: 2}},
+		}},
+	}}
+	Modify(program, func(node Node) Node {
+		if stmt, ok := node.(*ExpressionStatement); ok && stmt.String() == "drop" {
+			return nil
+		}
+		return node
+	})
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	block := program.Statements[1].(*BlockStatement)
+	if len(block.Statements) != 1 {
+		t.Fatalf("wrong number of block statements. got=%d", len(block.Statements))
+	}
+	if lit, ok := block.Statements[0].(*ExpressionStatement).Expression.(*IntegerLiteral); !ok || lit.Value != 2 {
+		t.Errorf("wrong statement left in block. got=%#v", block.Statements[0])
+	}
+}
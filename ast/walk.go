@@ -0,0 +1,141 @@
+package ast
+
+import "fmt"
+
+/*
+Visitor
+Walkがノードを訪れるたびにVisitを呼ぶ
+返されたVisitorが nil でなければ、そのVisitorで子ノードを訪れ、最後にVisit(nil)を呼ぶ
+*/
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+/*
+Walk
+nodeから深さ優先で構文木をたどる
+子はソースに現れる順に訪れ、nilの子は飛ばす
+*/
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean, *BadStatement, *BadExpression, *Comment,
+		*BreakStatement, *ContinueStatement:
+		// 子はない
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *LogicalExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *AssignExpression:
+		walkExpression(v, n.Target)
+		walkExpression(v, n.Value)
+	case *GroupedExpression:
+		walkExpression(v, n.Expression)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *WhileStatement:
+		walkExpression(v, n.Condition)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *ForInStatement:
+		if n.Variable != nil {
+			Walk(v, n.Variable)
+		}
+		walkExpression(v, n.Iterable)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			if p != nil {
+				Walk(v, p)
+			}
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkExpression(v Visitor, e Expression) {
+	if e != nil {
+		Walk(v, e)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		walkExpression(v, e)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		if s != nil {
+			Walk(v, s)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+/*
+Inspect
+nodeから深さ優先で構文木をたどり、各ノードでfを呼ぶ
+fがfalseを返すとそのノードの子は訪れない
+子をたどり終えるとf(nil)を呼ぶ
+*/
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,146 @@
+package ast_test
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors().Strings())
+	}
+	return program
+}
+
+func TestInspectOrder(t *testing.T) {
+	program := parse(t, `let f = fn(a, b) { if (a) { [a, (b)] } else { {"k": b}[a] } }; f(1, -2);`)
+	var kinds []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			kinds = append(kinds, fmt.Sprintf("%T", node)[5:])
+		}
+		return true
+	})
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier",
+		"FunctionLiteral", "Identifier", "Identifier",
+		"BlockStatement", "ExpressionStatement",
+		"IfExpression", "Identifier",
+		"BlockStatement", "ExpressionStatement", "ArrayLiteral", "Identifier", "GroupedExpression", "Identifier",
+		"BlockStatement", "ExpressionStatement", "IndexExpression", "HashLiteral", "StringLiteral", "Identifier", "Identifier",
+		"ExpressionStatement", "CallExpression", "Identifier", "IntegerLiteral", "PrefixExpression", "IntegerLiteral",
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("wrong order.\nwant=%v\ngot=%v", expected, kinds)
+	}
+}
+
+func TestInspectLoops(t *testing.T) {
+	program := parse(t, `while (a) { break; } for (x in xs) { continue }`)
+	var kinds []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			kinds = append(kinds, fmt.Sprintf("%T", node)[5:])
+		}
+		return true
+	})
+	expected := []string{
+		"Program",
+		"WhileStatement", "Identifier", "BlockStatement", "BreakStatement",
+		"ForInStatement", "Identifier", "Identifier", "BlockStatement", "ContinueStatement",
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("wrong order.\nwant=%v\ngot=%v", expected, kinds)
+	}
+}
+
+func TestInspectLogicalExpression(t *testing.T) {
+	program := parse(t, "a && b || c")
+	var idents []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		return true
+	})
+	if !reflect.DeepEqual(idents, []string{"a", "b", "c"}) {
+		t.Errorf("wrong identifiers. got=%v", idents)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, "let x = fn() { y }; z")
+	var idents []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.Identifier:
+			idents = append(idents, node.Value)
+		}
+		return true
+	})
+	if !reflect.DeepEqual(idents, []string{"x", "z"}) {
+		t.Errorf("wrong identifiers. got=%v", idents)
+	}
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+	leaves   *int
+}
+
+func (v depthVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*v.leaves++
+		return nil
+	}
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+	return depthVisitor{depth: v.depth + 1, maxDepth: v.maxDepth, leaves: v.leaves}
+}
+
+func TestWalkCallsVisitNilAfterChildren(t *testing.T) {
+	program := parse(t, "1 + 2")
+	var maxDepth, ends int
+	ast.Walk(depthVisitor{maxDepth: &maxDepth, leaves: &ends}, program)
+	// Program > ExpressionStatement > InfixExpression > IntegerLiteral
+	if maxDepth != 3 {
+		t.Errorf("wrong depth. got=%d", maxDepth)
+	}
+	// 訪れた5つのノードそれぞれの後にVisit(nil)が呼ばれる
+	if ends != 5 {
+		t.Errorf("wrong number of Visit(nil) calls. got=%d", ends)
+	}
+}
+
+func TestWalkSkipsNilChildren(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.LetStatement{Name: &ast.Identifier{Value: "x"}},
+		&ast.ExpressionStatement{Expression: &ast.IfExpression{
+			Condition:   &ast.Boolean{Value: true},
+			Consequence: &ast.BlockStatement{},
+		}},
+		&ast.ReturnStatement{},
+	}}
+	count := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			count++
+		}
+		return true
+	})
+	if count != 8 {
+		t.Errorf("wrong number of nodes. got=%d", count)
+	}
+}
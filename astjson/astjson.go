@@ -0,0 +1,376 @@
+/*
+Package astjson
+構文木をJSONに変換し、JSONから構文木を復元する
+
+ノードはすべて次の形のオブジェクトになる
+
+	{
+	  "kind":     "InfixExpression",           ノードの型名
+	  "token":    {"type", "literal", "pos", "end"},
+	  "pos":      {"offset", "line", "column"}, ノードの開始位置
+	  "end":      {"offset", "line", "column"}, ノードの直後の位置
+	  "operator": "+",                         演算子式のみ
+	  "value":    1,                           識別子とリテラルのみ
+	  "close":    {...},                       閉じ括弧のトークン
+	  "children": {"left": {...}, "right": {...}}
+	}
+
+childrenの値はノードかノードの配列で、欠けている子はnullになる
+ハッシュリテラルの組はkeyとvalueを子に持つ"HashPair"として表す
+Programのコメントはchildrenの"comments"に"Comment"として並ぶ
+位置のfilenameは空なら省略する
+*/
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/token"
+	"io"
+)
+
+type jsonPosition struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+type jsonToken struct {
+	Type    string       `json:"type"`
+	Literal string       `json:"literal"`
+	Pos     jsonPosition `json:"pos"`
+	End     jsonPosition `json:"end"`
+}
+
+/*
+jsonNode
+書き出すときのノードの表現
+childrenの値は*jsonNode、[]*jsonNode、nilのいずれか
+*/
+type jsonNode struct {
+	Kind     string                 `json:"kind"`
+	Token    *jsonToken             `json:"token,omitempty"`
+	Pos      jsonPosition           `json:"pos"`
+	End      jsonPosition           `json:"end"`
+	Operator string                 `json:"operator,omitempty"`
+	Value    interface{}            `json:"value,omitempty"`
+	Close    *jsonToken             `json:"close,omitempty"`
+	Children map[string]interface{} `json:"children,omitempty"`
+}
+
+/*
+Marshal
+ノードをJSONに変換する
+*/
+func Marshal(node ast.Node) ([]byte, error) {
+	n, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+/*
+Encode
+ノードを字下げしたJSONとしてwに書き込む
+*/
+func Encode(w io.Writer, node ast.Node) error {
+	n, err := encodeNode(node)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(n)
+}
+
+func encodePosition(p token.Position) jsonPosition {
+	return jsonPosition{Filename: p.Filename, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func encodeToken(t token.Token) *jsonToken {
+	return &jsonToken{
+		Type:    string(t.Type),
+		Literal: t.Literal,
+		Pos:     encodePosition(t.Pos),
+		End:     encodePosition(t.End),
+	}
+}
+
+/*
+encodeClose
+閉じ括弧のトークンを返す
+構文エラーで閉じ括弧がなかった場合はnilを返す
+*/
+func encodeClose(t token.Token) *jsonToken {
+	if t.Type == "" {
+		return nil
+	}
+	return encodeToken(t)
+}
+
+func encodeNode(node ast.Node) (*jsonNode, error) {
+	n := &jsonNode{Pos: encodePosition(node.Pos()), End: encodePosition(node.End())}
+	children := map[string]interface{}{}
+	var err error
+	child := func(name string, c ast.Node) {
+		if err != nil {
+			return
+		}
+		children[name], err = encodeChild(c)
+	}
+	list := func(name string, nodes []ast.Node) {
+		if err != nil {
+			return
+		}
+		encoded := []*jsonNode{}
+		for _, c := range nodes {
+			var e *jsonNode
+			if e, err = encodeChild(c); err != nil {
+				return
+			}
+			encoded = append(encoded, e)
+		}
+		children[name] = encoded
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		list("statements", statements(node.Statements))
+		if len(node.Comments) > 0 {
+			comments := make([]ast.Node, len(node.Comments))
+			for i, c := range node.Comments {
+				comments[i] = c
+			}
+			list("comments", comments)
+		}
+	case *ast.Comment:
+		n.Token = encodeToken(node.Token)
+	case *ast.LetStatement:
+		n.Token = encodeToken(node.Token)
+		child("name", identifier(node.Name))
+		child("value", node.Value)
+	case *ast.ReturnStatement:
+		n.Token = encodeToken(node.Token)
+		child("returnValue", node.ReturnValue)
+	case *ast.ExpressionStatement:
+		n.Token = encodeToken(node.Token)
+		child("expression", node.Expression)
+	case *ast.BlockStatement:
+		n.Token = encodeToken(node.Token)
+		n.Close = encodeClose(node.RBrace)
+		list("statements", statements(node.Statements))
+	case *ast.WhileStatement:
+		n.Token = encodeToken(node.Token)
+		child("condition", node.Condition)
+		child("body", block(node.Body))
+	case *ast.ForInStatement:
+		n.Token = encodeToken(node.Token)
+		child("variable", identifier(node.Variable))
+		child("iterable", node.Iterable)
+		child("body", block(node.Body))
+	case *ast.BreakStatement:
+		n.Token = encodeToken(node.Token)
+	case *ast.ContinueStatement:
+		n.Token = encodeToken(node.Token)
+	case *ast.Identifier:
+		n.Token = encodeToken(node.Token)
+		n.Value = node.Value
+	case *ast.IntegerLiteral:
+		n.Token = encodeToken(node.Token)
+		n.Value = node.Value
+	case *ast.FloatLiteral:
+		n.Token = encodeToken(node.Token)
+		n.Value = node.Value
+	case *ast.StringLiteral:
+		n.Token = encodeToken(node.Token)
+		n.Value = node.Value
+	case *ast.Boolean:
+		n.Token = encodeToken(node.Token)
+		n.Value = node.Value
+	case *ast.PrefixExpression:
+		n.Token = encodeToken(node.Token)
+		n.Operator = node.Operator
+		child("right", node.Right)
+	case *ast.InfixExpression:
+		n.Token = encodeToken(node.Token)
+		n.Operator = node.Operator
+		child("left", node.Left)
+		child("right", node.Right)
+	case *ast.LogicalExpression:
+		n.Token = encodeToken(node.Token)
+		n.Operator = node.Operator
+		child("left", node.Left)
+		child("right", node.Right)
+	case *ast.AssignExpression:
+		n.Token = encodeToken(node.Token)
+		n.Operator = node.Operator
+		child("target", node.Target)
+		child("value", node.Value)
+	case *ast.GroupedExpression:
+		n.Token = encodeToken(node.Token)
+		n.Close = encodeClose(node.RParen)
+		child("expression", node.Expression)
+	case *ast.IfExpression:
+		n.Token = encodeToken(node.Token)
+		child("condition", node.Condition)
+		child("consequence", block(node.Consequence))
+		child("alternative", block(node.Alternative))
+	case *ast.FunctionLiteral:
+		n.Token = encodeToken(node.Token)
+		params := make([]ast.Node, len(node.Parameters))
+		for i, p := range node.Parameters {
+			params[i] = identifier(p)
+		}
+		list("parameters", params)
+		child("body", block(node.Body))
+	case *ast.CallExpression:
+		n.Token = encodeToken(node.Token)
+		n.Close = encodeClose(node.RParen)
+		child("function", node.Function)
+		list("arguments", expressions(node.Arguments))
+	case *ast.ArrayLiteral:
+		n.Token = encodeToken(node.Token)
+		n.Close = encodeClose(node.RBracket)
+		list("elements", expressions(node.Elements))
+	case *ast.IndexExpression:
+		n.Token = encodeToken(node.Token)
+		n.Close = encodeClose(node.RBracket)
+		child("left", node.Left)
+		child("index", node.Index)
+	case *ast.HashLiteral:
+		n.Token = encodeToken(node.Token)
+		n.Close = encodeClose(node.RBrace)
+		pairs := []*jsonNode{}
+		for _, pair := range node.Pairs {
+			p, e := encodeHashPair(pair)
+			if e != nil {
+				return nil, e
+			}
+			pairs = append(pairs, p)
+		}
+		children["pairs"] = pairs
+	case *ast.BadStatement:
+		n.Token = encodeToken(node.Token)
+	case *ast.BadExpression:
+		n.Token = encodeToken(node.Token)
+	default:
+		return nil, fmt.Errorf("astjson: cannot encode %T", node)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Kind = kindOf(node)
+	if len(children) > 0 {
+		n.Children = children
+	}
+	return n, nil
+}
+
+func encodeHashPair(pair *ast.HashPair) (*jsonNode, error) {
+	key, err := encodeChild(pair.Key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := encodeChild(pair.Value)
+	if err != nil {
+		return nil, err
+	}
+	n := &jsonNode{Kind: kindHashPair, Children: map[string]interface{}{"key": key, "value": value}}
+	if pair.Key != nil {
+		n.Pos = encodePosition(pair.Key.Pos())
+	}
+	if pair.Value != nil {
+		n.End = encodePosition(pair.Value.End())
+	}
+	return n, nil
+}
+
+/*
+encodeChild
+子ノードを変換する
+nilのノードはJSONのnullになる
+*/
+func encodeChild(node ast.Node) (*jsonNode, error) {
+	if node == nil {
+		return nil, nil
+	}
+	return encodeNode(node)
+}
+
+// 型つきのnilを含むスライスやポインタを、nilのast.Nodeに揃える
+
+func statements(stmts []ast.Statement) []ast.Node {
+	nodes := make([]ast.Node, len(stmts))
+	for i, s := range stmts {
+		if s != nil {
+			nodes[i] = s
+		}
+	}
+	return nodes
+}
+
+func expressions(exps []ast.Expression) []ast.Node {
+	nodes := make([]ast.Node, len(exps))
+	for i, e := range exps {
+		if e != nil {
+			nodes[i] = e
+		}
+	}
+	return nodes
+}
+
+func identifier(i *ast.Identifier) ast.Node {
+	if i == nil {
+		return nil
+	}
+	return i
+}
+
+func block(b *ast.BlockStatement) ast.Node {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+const kindHashPair = "HashPair"
+
+/*
+kindOf
+ノードの型名を返す
+*/
+func kindOf(node ast.Node) string {
+	name := fmt.Sprintf("%T", node)
+	return name[len("*ast."):]
+}
+
+/*
+Unmarshal
+JSONから構文木を復元する
+*/
+func Unmarshal(data []byte) (ast.Node, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("astjson: %w", err)
+	}
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, fmt.Errorf("astjson: no node")
+	}
+	return decodeNode(raw)
+}
+
+/*
+Decode
+rからJSONを読み込んで構文木を復元する
+*/
+func Decode(r io.Reader) (ast.Node, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(data)
+}
@@ -0,0 +1,133 @@
+package astjson
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.NewFile("test.mk", input))
+	return p.ParseProgram()
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let x = 5; return x;",
+		"let add = fn(a, b) { a + b }; add(1, (2 * 3));",
+		`if (x < y) { "yes\n" } else { !false }`,
+		`let h = {"a": [1, 2][0], true: -x}; h["a"]`,
+		"fn() {}; []; {}",
+		"((a + b)) * c",
+		"let = 5; let y = 1 +; z",
+		"while (i < 3) { break; } for (x in [1]) { continue; }",
+		"for (x y) {}",
+		`x = y += 1; a["k"] *= 2; 1 = 2`,
+		"a && b || !c && (d || e)",
+		"let avg = 3.5 * .5 + 1e-9 - 0.0;",
+		"let mode = 0o755 & 0xFF | 0b1_0 + 1_000;",
+		"a <= b % 2 ** -c ** d | ~e & f ^ g << 1 >> 2",
+	}
+	for _, input := range inputs {
+		program := parse(t, input)
+		data, err := Marshal(program)
+		if err != nil {
+			t.Fatalf("%q: Marshal failed: %s", input, err)
+		}
+		node, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%q: Unmarshal failed: %s", input, err)
+		}
+		if !reflect.DeepEqual(node, program) {
+			t.Errorf("%q: round trip changed the tree.\nwant=%s\ngot=%s", input, program.String(), node.String())
+		}
+	}
+}
+
+func TestRoundTripComments(t *testing.T) {
+	l := lexer.NewFile("test.mk", "// note\nlet x = 1; /* trailing */")
+	l.SetMode(lexer.ScanComments)
+	program := parser.New(l).ParseProgram()
+	data, err := Marshal(program)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+	node, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	if !reflect.DeepEqual(node, program) {
+		t.Errorf("round trip changed the tree.\n%s", data)
+	}
+	if len(node.(*ast.Program).Comments) != 2 {
+		t.Errorf("comments lost.\n%s", data)
+	}
+}
+
+func TestEncodeSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, parse(t, "(1) + x")); err != nil {
+		t.Fatalf("Encode failed: %s", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`"kind": "Program"`,
+		`"kind": "InfixExpression"`,
+		`"operator": "+"`,
+		`"kind": "GroupedExpression"`,
+		`"kind": "IntegerLiteral"`,
+		`"value": 1`,
+		`"value": "x"`,
+		`"filename": "test.mk"`,
+		`"close": {`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %s.\n%s", want, got)
+		}
+	}
+}
+
+func TestEncodeIsStable(t *testing.T) {
+	program := parse(t, `let h = {"b": 1, "a": 2}; fn(x) { if (x) { h } }`)
+	first, err := Marshal(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		again, _ := Marshal(program)
+		if !bytes.Equal(first, again) {
+			t.Fatalf("output changed between runs")
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{`, "unexpected end of JSON input"},
+		{`null`, "no node"},
+		{`{"kind": "Loop"}`, `unknown node kind "Loop"`},
+		{`{"kind": "ExpressionStatement", "children": {"expression": {"kind": "Program"}}}`,
+			`ExpressionStatement: child "expression" is *ast.Program, not an expression`},
+		{`{"kind": "IntegerLiteral", "value": "five"}`, "IntegerLiteral: bad value"},
+		{`{"kind": "Program", "children": {"statements": {"kind": "Program"}}}`, `child "statements" is not a list`},
+		{`{"kind": "HashLiteral", "children": {"pairs": [{"kind": "Identifier"}]}}`, `pair has kind "Identifier"`},
+	}
+	for _, tt := range tests {
+		_, err := Unmarshal([]byte(tt.input))
+		if err == nil {
+			t.Errorf("%s: expected error", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
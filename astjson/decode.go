@@ -0,0 +1,327 @@
+package astjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/token"
+)
+
+/*
+rawNode
+読み込むときのノードの表現
+子ノードは必要になったときに種類を確かめながら復元する
+*/
+type rawNode struct {
+	Kind     string                     `json:"kind"`
+	Token    *jsonToken                 `json:"token"`
+	Pos      jsonPosition               `json:"pos"`
+	End      jsonPosition               `json:"end"`
+	Operator string                     `json:"operator"`
+	Value    json.RawMessage            `json:"value"`
+	Close    *jsonToken                 `json:"close"`
+	Children map[string]json.RawMessage `json:"children"`
+}
+
+func decodePosition(p jsonPosition) token.Position {
+	return token.Position{Filename: p.Filename, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func decodeToken(t *jsonToken) token.Token {
+	if t == nil {
+		return token.Token{}
+	}
+	return token.Token{
+		Type:    token.TokenType(t.Type),
+		Literal: t.Literal,
+		Pos:     decodePosition(t.Pos),
+		End:     decodePosition(t.End),
+	}
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+/*
+decodeNode
+JSONのオブジェクトから一つのノードを復元する
+*/
+func decodeNode(data json.RawMessage) (ast.Node, error) {
+	var n rawNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, fmt.Errorf("astjson: %w", err)
+	}
+	d := &decoder{n: &n}
+	tok := decodeToken(n.Token)
+	var node ast.Node
+
+	switch n.Kind {
+	case "Program":
+		program := &ast.Program{Statements: d.statements("statements")}
+		for _, c := range d.list("comments") {
+			comment, ok := c.(*ast.Comment)
+			if !ok {
+				d.fail("child %q is %T, not a comment", "comments", c)
+			}
+			program.Comments = append(program.Comments, comment)
+		}
+		node = program
+	case "Comment":
+		node = &ast.Comment{Token: tok}
+	case "LetStatement":
+		node = &ast.LetStatement{Token: tok, Name: d.identifier("name"), Value: d.expression("value")}
+	case "ReturnStatement":
+		node = &ast.ReturnStatement{Token: tok, ReturnValue: d.expression("returnValue")}
+	case "ExpressionStatement":
+		node = &ast.ExpressionStatement{Token: tok, Expression: d.expression("expression")}
+	case "BlockStatement":
+		node = &ast.BlockStatement{Token: tok, Statements: d.statements("statements"), RBrace: decodeToken(n.Close)}
+	case "WhileStatement":
+		node = &ast.WhileStatement{Token: tok, Condition: d.expression("condition"), Body: d.block("body")}
+	case "ForInStatement":
+		node = &ast.ForInStatement{
+			Token:    tok,
+			Variable: d.identifier("variable"),
+			Iterable: d.expression("iterable"),
+			Body:     d.block("body"),
+		}
+	case "BreakStatement":
+		node = &ast.BreakStatement{Token: tok}
+	case "ContinueStatement":
+		node = &ast.ContinueStatement{Token: tok}
+	case "Identifier":
+		i := &ast.Identifier{Token: tok}
+		d.value(&i.Value)
+		node = i
+	case "IntegerLiteral":
+		i := &ast.IntegerLiteral{Token: tok}
+		d.value(&i.Value)
+		node = i
+	case "FloatLiteral":
+		f := &ast.FloatLiteral{Token: tok}
+		d.value(&f.Value)
+		node = f
+	case "StringLiteral":
+		s := &ast.StringLiteral{Token: tok}
+		d.value(&s.Value)
+		node = s
+	case "Boolean":
+		b := &ast.Boolean{Token: tok}
+		d.value(&b.Value)
+		node = b
+	case "PrefixExpression":
+		node = &ast.PrefixExpression{Token: tok, Operator: n.Operator, Right: d.expression("right")}
+	case "InfixExpression":
+		node = &ast.InfixExpression{Token: tok, Left: d.expression("left"), Operator: n.Operator, Right: d.expression("right")}
+	case "LogicalExpression":
+		node = &ast.LogicalExpression{Token: tok, Left: d.expression("left"), Operator: n.Operator, Right: d.expression("right")}
+	case "AssignExpression":
+		node = &ast.AssignExpression{Token: tok, Target: d.expression("target"), Operator: n.Operator, Value: d.expression("value")}
+	case "GroupedExpression":
+		node = &ast.GroupedExpression{Token: tok, Expression: d.expression("expression"), RParen: decodeToken(n.Close)}
+	case "IfExpression":
+		node = &ast.IfExpression{
+			Token:       tok,
+			Condition:   d.expression("condition"),
+			Consequence: d.block("consequence"),
+			Alternative: d.block("alternative"),
+		}
+	case "FunctionLiteral":
+		f := &ast.FunctionLiteral{Token: tok}
+		for _, p := range d.list("parameters") {
+			f.Parameters = append(f.Parameters, d.asIdentifier("parameters", p))
+		}
+		f.Body = d.block("body")
+		node = f
+	case "CallExpression":
+		node = &ast.CallExpression{
+			Token:     tok,
+			Function:  d.expression("function"),
+			Arguments: d.expressions("arguments"),
+			RParen:    decodeToken(n.Close),
+		}
+	case "ArrayLiteral":
+		node = &ast.ArrayLiteral{Token: tok, Elements: d.expressions("elements"), RBracket: decodeToken(n.Close)}
+	case "IndexExpression":
+		node = &ast.IndexExpression{
+			Token:    tok,
+			Left:     d.expression("left"),
+			Index:    d.expression("index"),
+			RBracket: decodeToken(n.Close),
+		}
+	case "HashLiteral":
+		h := &ast.HashLiteral{Token: tok, RBrace: decodeToken(n.Close)}
+		for _, raw := range d.rawList("pairs") {
+			h.Pairs = append(h.Pairs, d.hashPair(raw))
+		}
+		node = h
+	case "BadStatement":
+		node = &ast.BadStatement{Token: tok, From: decodePosition(n.Pos), To: decodePosition(n.End)}
+	case "BadExpression":
+		node = &ast.BadExpression{Token: tok, From: decodePosition(n.Pos), To: decodePosition(n.End)}
+	default:
+		return nil, fmt.Errorf("astjson: unknown node kind %q", n.Kind)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+/*
+decoder
+一つのノードの子を復元する
+最初のエラーを覚えておき、以降の処理は何もしない
+*/
+type decoder struct {
+	n   *rawNode
+	err error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("astjson: %s: %s", d.n.Kind, fmt.Sprintf(format, args...))
+	}
+}
+
+func (d *decoder) value(v interface{}) {
+	if d.err != nil {
+		return
+	}
+	if err := json.Unmarshal(d.n.Value, v); err != nil {
+		d.fail("bad value: %s", err)
+	}
+}
+
+func (d *decoder) decode(raw json.RawMessage) ast.Node {
+	if d.err != nil || isNull(raw) {
+		return nil
+	}
+	node, err := decodeNode(raw)
+	if err != nil {
+		d.err = err
+		return nil
+	}
+	return node
+}
+
+func (d *decoder) child(name string) ast.Node {
+	return d.decode(d.n.Children[name])
+}
+
+func (d *decoder) rawList(name string) []json.RawMessage {
+	raw := d.n.Children[name]
+	if d.err != nil || isNull(raw) {
+		return nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		d.fail("child %q is not a list", name)
+		return nil
+	}
+	return list
+}
+
+func (d *decoder) list(name string) []ast.Node {
+	var nodes []ast.Node
+	for _, raw := range d.rawList(name) {
+		nodes = append(nodes, d.decode(raw))
+	}
+	return nodes
+}
+
+func (d *decoder) asExpression(name string, node ast.Node) ast.Expression {
+	if node == nil {
+		return nil
+	}
+	e, ok := node.(ast.Expression)
+	if !ok {
+		d.fail("child %q is %T, not an expression", name, node)
+	}
+	return e
+}
+
+func (d *decoder) asStatement(name string, node ast.Node) ast.Statement {
+	if node == nil {
+		return nil
+	}
+	s, ok := node.(ast.Statement)
+	if !ok {
+		d.fail("child %q is %T, not a statement", name, node)
+	}
+	return s
+}
+
+func (d *decoder) asIdentifier(name string, node ast.Node) *ast.Identifier {
+	if node == nil {
+		return nil
+	}
+	i, ok := node.(*ast.Identifier)
+	if !ok {
+		d.fail("child %q is %T, not an identifier", name, node)
+	}
+	return i
+}
+
+func (d *decoder) expression(name string) ast.Expression {
+	return d.asExpression(name, d.child(name))
+}
+
+func (d *decoder) identifier(name string) *ast.Identifier {
+	return d.asIdentifier(name, d.child(name))
+}
+
+func (d *decoder) block(name string) *ast.BlockStatement {
+	node := d.child(name)
+	if node == nil {
+		return nil
+	}
+	b, ok := node.(*ast.BlockStatement)
+	if !ok {
+		d.fail("child %q is %T, not a block", name, node)
+	}
+	return b
+}
+
+/*
+statements
+文の並びを復元する
+構文解析器にあわせて、文の並びは空でもnilにしない
+*/
+func (d *decoder) statements(name string) []ast.Statement {
+	stmts := []ast.Statement{}
+	for _, node := range d.list(name) {
+		stmts = append(stmts, d.asStatement(name, node))
+	}
+	return stmts
+}
+
+func (d *decoder) expressions(name string) []ast.Expression {
+	var exps []ast.Expression
+	for _, node := range d.list(name) {
+		exps = append(exps, d.asExpression(name, node))
+	}
+	return exps
+}
+
+func (d *decoder) hashPair(raw json.RawMessage) *ast.HashPair {
+	if d.err != nil {
+		return nil
+	}
+	var n rawNode
+	if err := json.Unmarshal(raw, &n); err != nil {
+		d.fail("bad pair: %s", err)
+		return nil
+	}
+	if n.Kind != kindHashPair {
+		d.fail("pair has kind %q", n.Kind)
+		return nil
+	}
+	pd := &decoder{n: &n}
+	pair := &ast.HashPair{Key: pd.expression("key"), Value: pd.expression("value")}
+	if pd.err != nil {
+		d.err = pd.err
+	}
+	return pair
+}
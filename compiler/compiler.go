@@ -0,0 +1,735 @@
+package compiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/code"
+	"interpreter/object"
+	"strings"
+)
+
+/*
+EmittedInstruction
+出力した命令とその位置
+*/
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+/*
+CompilationScope
+関数ごとの命令列
+*/
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop // コンパイル中のループ、内側のものほど後ろ
+	operands            int     // 積んだまま、続く式の値を待っている値の数
+}
+
+/*
+loop
+コンパイル中のループ
+continueはcontinuePosへ飛ぶ
+breakのジャンプ先はループの終わりが決まってから書き換える
+operandsはループに入ったときに積んだままだった値の数で、
+式の途中のbreakとcontinueはそれより上の値を取り除いてから飛ぶ
+*/
+type loop struct {
+	continuePos int
+	breakJumps  []int
+	operands    int
+}
+
+/*
+Compiler
+ASTをバイトコードに変換する
+*/
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	symbolTable := NewSymbolTable()
+	for i, b := range object.Builtins {
+		symbolTable.DefineBuiltin(i, b.Name)
+	}
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+/*
+NewWithState
+REPLで行をまたいで定数と識別子を引き継ぐためのCompilerを生成する
+*/
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+/*
+Bytecode
+VMに渡すコンパイル結果
+GlobalNamesは値のないグローバル変数を読んだときのエラーに使う名前で、番号で引く
+*/
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	GlobalNames  []string
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.names(),
+	}
+}
+
+/*
+Compile
+ノードをコンパイルする
+*/
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		// 右辺を先にコンパイルし、右辺からは以前の束縛が見えるようにする
+		// 関数リテラルは本体から自分自身を参照できるように、名前を先に定義する
+		if fl, ok := ast.Unparen(node.Value).(*ast.FunctionLiteral); ok {
+			symbol := c.symbolTable.Define(node.Name.Value)
+			if err := c.compileFunctionLiteral(fl); err != nil {
+				return err
+			}
+			c.storeSymbol(symbol)
+			return nil
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("break outside loop")
+		}
+		c.popOperands(l)
+		l.breakJumps = append(l.breakJumps, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("continue outside loop")
+		}
+		c.popOperands(l)
+		c.emit(code.OpJump, l.continuePos)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+	case *ast.LogicalExpression:
+		return c.compileLogicalExpression(node)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.ArrayLiteral:
+		if err := c.compileOperands(node.Elements...); err != nil {
+			return err
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		var operands []ast.Expression
+		for _, pair := range node.Pairs {
+			operands = append(operands, pair.Key, pair.Value)
+		}
+		if err := c.compileOperands(operands...); err != nil {
+			return err
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.compileOperands(node.Left, node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		operands := append([]ast.Expression{node.Function}, node.Arguments...)
+		if err := c.compileOperands(operands...); err != nil {
+			return err
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.GroupedExpression:
+		return c.Compile(node.Expression)
+	case *ast.BadStatement:
+		return fmt.Errorf("invalid statement at %s", node.Pos())
+	case *ast.BadExpression:
+		return fmt.Errorf("invalid expression at %s", node.Pos())
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+	return nil
+}
+
+/*
+compileInfixExpression
+中置演算子式をコンパイルする
+評価器と同じく左辺から順に評価する
+*/
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	if err := c.compileOperands(node.Left, node.Right); err != nil {
+		return err
+	}
+	return c.emitOperator(node.Operator)
+}
+
+/*
+compileOperands
+演算に使う値を順にコンパイルしてスタックに積む
+先に積んだ値は、後の値をコンパイルしている間は積んだままの値として数える
+*/
+func (c *Compiler) compileOperands(nodes ...ast.Expression) error {
+	for _, n := range nodes {
+		if err := c.Compile(n); err != nil {
+			return err
+		}
+		c.addOperands(1)
+	}
+	c.addOperands(-len(nodes))
+	return nil
+}
+
+/*
+addOperands
+積んだまま、続く式の値を待っている値の数を増減する
+*/
+func (c *Compiler) addOperands(n int) {
+	c.scopes[c.scopeIndex].operands += n
+}
+
+/*
+popOperands
+ループに入ってから積んだままの値を取り除く
+式の途中のbreakとcontinueでスタックに値を残さないために使う
+*/
+func (c *Compiler) popOperands(l *loop) {
+	for i := l.operands; i < c.scopes[c.scopeIndex].operands; i++ {
+		c.emit(code.OpPop)
+	}
+}
+
+/*
+compileLogicalExpression
+論理演算子の式をジャンプ命令でコンパイルする
+"||"は左辺を否定して判定し、真なら右辺を飛ばしてtrueを残す
+"&&"は左辺が偽なら右辺を飛ばしてfalseを残す
+*/
+func (c *Compiler) compileLogicalExpression(node *ast.LogicalExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	var leftJumpPos int
+	switch node.Operator {
+	case "&&":
+		leftJumpPos = c.emit(code.OpJumpNotTruthy, 9999)
+	case "||":
+		c.emit(code.OpBang)
+		leftJumpPos = c.emit(code.OpJumpNotTruthy, 9999)
+	default:
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	rightJumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+	truePos := c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+	falsePos := c.emit(code.OpFalse)
+	c.changeOperand(rightJumpPos, falsePos)
+	if node.Operator == "&&" {
+		c.changeOperand(leftJumpPos, falsePos)
+	} else {
+		c.changeOperand(leftJumpPos, truePos)
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+/*
+emitOperator
+中置演算子に対応する命令を出力する
+*/
+func (c *Compiler) emitOperator(operator string) error {
+	switch operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "**":
+		c.emit(code.OpPow)
+	case "&":
+		c.emit(code.OpBitAnd)
+	case "|":
+		c.emit(code.OpBitOr)
+	case "^":
+		c.emit(code.OpBitXor)
+	case "<<":
+		c.emit(code.OpShiftLeft)
+	case ">>":
+		c.emit(code.OpShiftRight)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
+	case ">=":
+		c.emit(code.OpGreaterEqual)
+	case "<=":
+		c.emit(code.OpLessEqual)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("unknown operator %s", operator)
+	}
+	return nil
+}
+
+/*
+compileAssignExpression
+代入式をコンパイルし、代入した値をスタックに残す
+複合代入では代入先の今の値を先に積み、右辺との演算結果を代入する
+
+自由変数は捕捉した関数とCellを共有しているので、自由変数への代入は
+外側の関数の変数と、同じ変数を捕捉したほかのクロージャにも見える
+*/
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	compound := node.Operator != "="
+	operator := strings.TrimSuffix(node.Operator, "=")
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("assignment to undeclared variable: %s", target.Value)
+		}
+		if compound {
+			c.loadSymbol(symbol)
+			c.addOperands(1)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.addOperands(-1)
+			if err := c.emitOperator(operator); err != nil {
+				return err
+			}
+		}
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		// 右辺のコンパイル中は、代入先の配列と添字、複合代入なら今の値を積んだままにする
+		pending := 2
+		if err := c.compileOperands(target.Left, target.Index); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+			pending = 3
+		}
+		c.addOperands(pending)
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.addOperands(-pending)
+		if compound {
+			if err := c.emitOperator(operator); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSetIndex)
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+	return nil
+}
+
+/*
+compileIfExpression
+IF式をコンパイルする
+ジャンプ先は各節をコンパイルした後に書き換える
+*/
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	// ダミーのジャンプ先を後で書き換える
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+	}
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+	return nil
+}
+
+/*
+compileBlockValue
+ブロックを値を一つ残す式としてコンパイルする
+最後の文が式でなければNULLを残す
+*/
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+/*
+compileWhileStatement
+while文をコンパイルする
+本体の最後で条件の評価に戻り、条件が偽ならループの後ろへ飛ぶ
+*/
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	loopStart := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileLoopBody(node.Body, loopStart); err != nil {
+		return err
+	}
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	return nil
+}
+
+/*
+compileForInStatement
+for-in文をコンパイルする
+反復子は名前のない変数に置き、OpIterNextで次の値を取り出す
+値が尽きたらループの後ろへ飛ぶ
+*/
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	iterator := c.symbolTable.defineHidden()
+	c.storeSymbol(iterator)
+	loopStart := len(c.currentInstructions())
+	c.loadSymbol(iterator)
+	exitPos := c.emit(code.OpIterNext, 9999)
+	c.storeSymbol(c.symbolTable.Define(node.Variable.Value))
+	if err := c.compileLoopBody(node.Body, loopStart); err != nil {
+		return err
+	}
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	return nil
+}
+
+/*
+compileLoopBody
+ループの本体をコンパイルし、最後にloopStartへ戻るジャンプを置く
+本体の中のbreakはループの後ろへ飛ぶように書き換える
+*/
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, loopStart int) error {
+	// 本体の関数リテラルでscopesが伸び縮みするので、ポインタではなく添字で参照する
+	scopeIndex := c.scopeIndex
+	l := &loop{continuePos: loopStart, operands: c.scopes[scopeIndex].operands}
+	c.scopes[scopeIndex].loops = append(c.scopes[scopeIndex].loops, l)
+	err := c.Compile(body)
+	loops := c.scopes[scopeIndex].loops
+	c.scopes[scopeIndex].loops = loops[:len(loops)-1]
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, loopStart)
+	afterLoopPos := len(c.currentInstructions())
+	for _, pos := range l.breakJumps {
+		c.changeOperand(pos, afterLoopPos)
+	}
+	return nil
+}
+
+/*
+currentLoop
+コンパイル中の一番内側のループを返す
+関数の外側のループは含まない
+*/
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+/*
+compileFunctionLiteral
+関数リテラルをコンパイルする
+*/
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.names()
+	instructions := c.leaveScope()
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		c.loadCell(s)
+		freeNames[i] = s.Name
+	}
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+/*
+loadCell
+自由変数として捕捉する変数のCellをスタックに積む
+ローカル変数とほかの自由変数は、同じCellを共有して代入が互いに見えるようにする
+*/
+func (c *Compiler) loadCell(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+/*
+storeSymbol
+スタックの先頭の値を取り出して変数に格納する
+*/
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+/*
+emit
+命令を出力し、その位置を返す
+*/
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+	old := c.currentInstructions()
+	c.scopes[c.scopeIndex].instructions = old[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+/*
+changeOperand
+posにある命令のオペランドを書き換える
+*/
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
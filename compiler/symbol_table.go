@@ -0,0 +1,144 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+/*
+Symbol
+識別子に対応するスコープと番号
+*/
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+/*
+SymbolTable
+識別子とSymbolの対応表
+関数ごとに外側の表を指す表を作る
+*/
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	FreeSymbols    []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s}
+}
+
+/*
+NewEnclosedSymbolTable
+outerを外側に持つ表を生成する
+*/
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+/*
+Define
+識別子を現在のスコープに定義する
+同じスコープに同じ名前の変数があればその場所を使い回す
+評価器のlet文と同じく、再定義は既存の束縛を書き換える
+ループの中のlet文や、再定義の前に作ったクロージャからも新しい値が見える
+外側の関数の変数や組み込み関数と同じ名前なら、新しい変数として隠す
+*/
+func (s *SymbolTable) Define(name string) Symbol {
+	if existing, ok := s.store[name]; ok && (existing.Scope == GlobalScope || existing.Scope == LocalScope) {
+		return existing
+	}
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+/*
+defineHidden
+名前を持たない変数を現在のスコープに定義する
+for-in文の反復子のように、コンパイラだけが使う値を置くために使う
+*/
+func (s *SymbolTable) defineHidden() Symbol {
+	symbol := Symbol{Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+	s.numDefinitions++
+	return symbol
+}
+
+/*
+names
+番号ごとに、その場所に定義した変数の名前を返す
+名前のない変数の場所は空文字列になる
+*/
+func (s *SymbolTable) names() []string {
+	names := make([]string, s.numDefinitions)
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			names[symbol.Index] = symbol.Name
+		}
+	}
+	return names
+}
+
+/*
+DefineBuiltin
+組み込み関数を番号とともに定義する
+*/
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+/*
+defineFree
+外側の関数のローカル変数を自由変数として定義する
+*/
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+/*
+Resolve
+識別子を解決する
+外側の関数のローカル変数は自由変数として扱う
+*/
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+		free := s.defineFree(obj)
+		return free, true
+	}
+	return obj, ok
+}
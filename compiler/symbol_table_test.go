@@ -0,0 +1,101 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+	local := NewEnclosedSymbolTable(global)
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+}
+
+func TestRedefineReusesIndex(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a in the same scope moved it. want=%+v, got=%+v", a, again)
+	}
+	local := NewEnclosedSymbolTable(global)
+	if shadow := local.Define("a"); shadow != (Symbol{Name: "a", Scope: LocalScope, Index: 0}) {
+		t.Errorf("defining a in an inner scope did not shadow it. got=%+v", shadow)
+	}
+	if hidden := local.defineHidden(); hidden != (Symbol{Scope: LocalScope, Index: 1}) {
+		t.Errorf("wrong hidden symbol. got=%+v", hidden)
+	}
+	if again := local.Define("a"); again != (Symbol{Name: "a", Scope: LocalScope, Index: 0}) {
+		t.Errorf("redefining a local moved it. got=%+v", again)
+	}
+	if outer, _ := global.Resolve("a"); outer != a {
+		t.Errorf("shadowing in an inner scope changed the outer symbol. got=%+v", outer)
+	}
+}
+
+func TestDefineShadowsFreeAndBuiltin(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	if shadow := global.Define("len"); shadow != (Symbol{Name: "len", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("defining over a builtin did not make a global. got=%+v", shadow)
+	}
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("x")
+	inner := NewEnclosedSymbolTable(outer)
+	if free, _ := inner.Resolve("x"); free != (Symbol{Name: "x", Scope: FreeScope, Index: 0}) {
+		t.Fatalf("x did not resolve as free. got=%+v", free)
+	}
+	if shadow := inner.Define("x"); shadow != (Symbol{Name: "x", Scope: LocalScope, Index: 0}) {
+		t.Errorf("defining over a free variable did not make a local. got=%+v", shadow)
+	}
+	if len(inner.FreeSymbols) != 1 {
+		t.Errorf("free symbols changed. got=%+v", inner.FreeSymbols)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "len", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: FreeScope, Index: 0},
+		{Name: "e", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0] != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+	if _, ok := secondLocal.Resolve("nope"); ok {
+		t.Errorf("unknown name resolved")
+	}
+}
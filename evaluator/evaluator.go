@@ -0,0 +1,752 @@
+package evaluator
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/object"
+	"math"
+	"strings"
+)
+
+var (
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+)
+
+/*
+Eval
+ASTのノードを評価して値を返す
+*/
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	// 文
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.ReturnStatement:
+		val := Eval(node.ReturnValue, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.BreakStatement:
+		return &object.Break{}
+	case *ast.ContinueStatement:
+		return &object.Continue{}
+	// 式
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.Boolean:
+		return object.NativeBoolToBooleanObject(node.Value)
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	case *ast.LogicalExpression:
+		return evalLogicalExpression(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isAbrupt(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args, env)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.GroupedExpression:
+		return Eval(node.Expression, env)
+	case *ast.BadStatement:
+		return newError("invalid statement at %s", node.Pos())
+	case *ast.BadExpression:
+		return newError("invalid expression at %s", node.Pos())
+	}
+	return nil
+}
+
+/*
+evalProgram
+プログラムの文を順に評価する
+return文かエラーに出会ったらそこで評価を打ち切る
+*/
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		case *object.Break, *object.Continue:
+			return outsideLoopError(result)
+		}
+	}
+	return result
+}
+
+/*
+evalBlockStatement
+ブロック文を評価する
+ReturnValue、Break、Continueはアンラップせずに外側へ伝播させる
+値を残す文がなければNULLを返す
+*/
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+		if isAbrupt(result) {
+			return result
+		}
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+/*
+evalPrefixExpression
+前置演算子式を評価する
+*/
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	switch right {
+	case TRUE:
+		return FALSE
+	case FALSE:
+		return TRUE
+	case NULL:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	value := right.(*object.Integer).Value
+	return &object.Integer{Value: ^value}
+}
+
+/*
+evalInfixExpression
+中置演算子式を評価する
+整数と浮動小数点数を混ぜると、整数を浮動小数点数に変換して計算する
+*/
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case operator == "==":
+		return object.NativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return object.NativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError("negative exponent: %d", rightVal)
+		}
+		return &object.Integer{Value: object.IntPow(leftVal, rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << uint64(rightVal)}
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
+	case "<":
+		return object.NativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return object.NativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return object.NativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return object.NativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return object.NativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return object.NativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+/*
+evalFloatInfixExpression
+浮動小数点数の演算を評価する
+整数と同じく、ゼロによる除算はエラーになる
+*/
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := object.FloatValue(left)
+	rightVal, _ := object.FloatValue(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return object.NativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return object.NativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return object.NativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return object.NativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return object.NativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return object.NativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := object.FloatValue(obj)
+	return ok
+}
+
+/*
+evalStringInfixExpression
+文字列の連結と比較を評価する
+*/
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return object.NativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return object.NativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+/*
+evalLogicalExpression
+論理演算子の式を評価する
+左辺で結果が決まれば右辺は評価しない
+値の真偽は"!"と同じくisTruthyで判定し、結果は常に真偽値になる
+*/
+func evalLogicalExpression(node *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	switch node.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return FALSE
+		}
+	case "||":
+		if isTruthy(left) {
+			return TRUE
+		}
+	default:
+		return newError("unknown operator: %s %s", left.Type(), node.Operator)
+	}
+	right := Eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return object.NativeBoolToBooleanObject(isTruthy(right))
+}
+
+/*
+evalAssignExpression
+代入式を評価し、代入した値を返す
+複合代入では、代入先の今の値と右辺に演算子を適用した結果を代入する
+*/
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIdentifier(target, env)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		val := evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+		if !env.Assign(target.Value, val) {
+			return newError("assignment to undeclared variable: %s", target.Value)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIndexExpression(left, index)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		val := evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return evalIndexAssignment(left, index, val)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+/*
+evalAssignedValue
+代入する値を求める
+単純な代入では右辺の値、複合代入ではcurrentと右辺の演算結果になる
+*/
+func evalAssignedValue(node *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isAbrupt(val) || current == nil {
+		return val
+	}
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return evalInfixExpression(operator, current, val)
+}
+
+/*
+evalIndexAssignment
+配列の要素かハッシュの値を置き換える
+配列の範囲外の添字はエラーになり、ハッシュにキーがなければ追加する
+*/
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= int64(len(elements)) {
+			return newError("index out of range: %d", i)
+		}
+		elements[i] = val
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+	default:
+		return newError("index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+	return val
+}
+
+/*
+evalIfExpression
+IF式を評価する
+条件が偽でelse節がなければNULLを返す
+*/
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isAbrupt(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	} else {
+		return NULL
+	}
+}
+
+/*
+evalWhileStatement
+条件が真の間、本体を繰り返し評価する
+ループ自体は値を残さない
+*/
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+		if result, done := evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+/*
+evalForInStatement
+繰り返しの対象の値を一つずつ変数に束縛して本体を評価する
+変数はlet文と同じく現在の環境に束縛する
+*/
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+	it, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+	for {
+		value, ok := it.Next()
+		if !ok {
+			return nil
+		}
+		env.Set(fs.Variable.Value, value)
+		if result, done := evalLoopBody(fs.Body, env); done {
+			return result
+		}
+	}
+}
+
+/*
+evalLoopBody
+ループの本体を一回評価する
+ループを抜けるときはtrueと、ループの代わりに返す値を返す
+*/
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	switch result := Eval(body, env).(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	}
+	return nil, false
+}
+
+/*
+isTruthy
+NULLとfalse以外はすべて真とみなす
+*/
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+/*
+evalIdentifier
+識別子を評価する
+環境に束縛がなければ組み込み関数を探す
+*/
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+/*
+evalIndexExpression
+添字式を評価する
+*/
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+/*
+evalArrayIndexExpression
+配列の要素を取り出す
+範囲外の添字(負の添字を含む)にはNULLを返す
+*/
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+	if idx < 0 || idx > max {
+		return NULL
+	}
+	return arrayObject.Elements[idx]
+}
+
+/*
+evalHashIndexExpression
+ハッシュから値を取り出す
+キーがなければNULLを返す
+*/
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+	return pair.Value
+}
+
+/*
+evalHashLiteral
+ハッシュリテラルを評価する
+キーと値はソースに書かれた順に評価する
+*/
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		value := Eval(pair.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+/*
+evalExpressions
+式のリストを左から順に評価する
+エラーやbreakなどで評価が打ち切られたら、その値のみを返す
+*/
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isAbrupt(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+/*
+applyFunction
+関数を引数に適用する
+組み込み関数には呼び出し元の環境の出力先を渡す
+*/
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if len(args) != len(function.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+		}
+		extendedEnv := extendFunctionEnv(function, args)
+		evaluated := Eval(function.Body, extendedEnv)
+		return unwrapReturnValue(evaluated)
+	case *object.Builtin:
+		return function.Fn(env.Output(), args...)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+/*
+extendFunctionEnv
+関数が定義された環境を外側に持つ環境を作り、引数を仮引数に束縛する
+*/
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+	for paramIdx, param := range fn.Parameters {
+		env.Set(param.Value, args[paramIdx])
+	}
+	return env
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.ReturnValue:
+		return obj.Value
+	case *object.Break, *object.Continue:
+		return outsideLoopError(obj)
+	}
+	return obj
+}
+
+/*
+outsideLoopError
+ループの外まで伝播したbreakやcontinueをエラーにする
+構文解析器を通さずに組み立てた構文木でだけ起こる
+*/
+func outsideLoopError(obj object.Object) *object.Error {
+	return newError("%s outside loop", obj.Inspect())
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+/*
+isAbrupt
+評価を打ち切って外側へ伝播させる値かを判定する
+エラーのほか、return、break、continueの結果が当たる
+*/
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
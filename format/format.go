@@ -0,0 +1,399 @@
+/*
+Package format
+Monkeyのソースコードを決まった形に整形する
+
+  - 括弧は構文解析器の優先順位表から必要なものだけを残す
+  - ブロックの中身は二つの空白で字下げする
+  - 文の区切りは一つの改行にそろえ、空行は一つまで残す
+  - コメントは元の位置に近い文の前か後ろに残す
+  - let文、return文、break文、continue文、式文はセミコロンで終える
+    ただし、if式の文は次の文とつながってしまう場合だけセミコロンを付ける
+
+整形した結果をもう一度整形しても変わらない
+*/
+package format
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/token"
+	"io"
+	"strings"
+)
+
+const indent = "  "
+
+/*
+Source
+ソースコードを構文解析して整形する
+構文エラーがあれば整形せずにparser.ErrorListを返す
+*/
+func Source(filename string, src []byte) ([]byte, error) {
+	l := lexer.NewFile(filename, string(src))
+	l.SetMode(lexer.ScanComments)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if err := p.Errors().Err(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := Node(&buf, program); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+Node
+ノードを整形してwに書き込む
+Programの場合はコメントも書き、末尾に改行を付ける
+*/
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		p.comments = node.Comments
+		p.statements(node.Statements, endOfFile, false)
+		if p.buf.Len() > 0 {
+			p.buf.WriteByte('\n')
+		}
+	case ast.Statement:
+		p.statement(node)
+		p.terminate(node, nil)
+	case ast.Expression:
+		p.expression(node, parser.LOWEST)
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// 残りのコメントをすべて書き出すときに使うオフセット
+const endOfFile = int(^uint(0) >> 1)
+
+/*
+printer
+整形した結果を溜めるバッファと現在の字下げの深さ
+commentsはまだ書いていないコメントで、ソースに現れる順に並ぶ
+*/
+type printer struct {
+	buf      bytes.Buffer
+	depth    int
+	comments []*ast.Comment
+	lastLine int // 最後に書いた文かコメントが終わるソース上の行
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+}
+
+/*
+newline
+改行し、次の行を現在の深さまで字下げする
+ソースでlineと直前に書いたものとの間に空行があれば、空行を一つ入れる
+*/
+func (p *printer) newline(line int, allowBlank bool) {
+	p.buf.WriteByte('\n')
+	if allowBlank && p.lastLine > 0 && line-p.lastLine > 1 {
+		p.buf.WriteByte('\n')
+	}
+	p.write(strings.Repeat(indent, p.depth))
+}
+
+func (p *printer) setLine(line int) {
+	if line > p.lastLine {
+		p.lastLine = line
+	}
+}
+
+/*
+statements
+文を一行に一つずつ書く
+文の前にあるコメントは文の前の行に、文と同じ行で終わるコメントは文の後ろに書く
+endより前に残っているコメントは最後の文の後に書く
+inBlockなら最初の文の前でも改行する
+*/
+func (p *printer) statements(stmts []ast.Statement, end int, inBlock bool) {
+	started := false
+	separate := func(line int) {
+		if started || inBlock {
+			p.newline(line, started)
+		}
+		started = true
+	}
+	for i, s := range stmts {
+		p.commentsBefore(s.Pos().Offset, separate)
+		separate(s.Pos().Line)
+		p.statement(s)
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		p.terminate(s, next)
+		p.setLine(s.End().Line)
+		p.trailingComments(end)
+	}
+	p.commentsBefore(end, separate)
+}
+
+/*
+commentsBefore
+offsetより前にあるコメントをそれぞれ一行に書く
+*/
+func (p *printer) commentsBefore(offset int, separate func(line int)) {
+	for len(p.comments) > 0 && p.comments[0].Pos().Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		separate(c.Pos().Line)
+		p.write(c.Token.Literal)
+		p.setLine(c.End().Line)
+		p.trailingComments(offset)
+	}
+}
+
+/*
+trailingComments
+直前に書いたものと同じ行で始まり、endより前にあるコメントを同じ行の後ろに続けて書く
+*/
+func (p *printer) trailingComments(end int) {
+	for len(p.comments) > 0 && p.lastLine > 0 && p.comments[0].Pos().Line == p.lastLine &&
+		p.comments[0].Pos().Offset < end {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.write(" ")
+		p.write(c.Token.Literal)
+		p.setLine(c.End().Line)
+	}
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let ")
+		p.write(s.Name.Value)
+		p.write(" = ")
+		p.expression(s.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if s.ReturnValue != nil {
+			p.write(" ")
+			p.expression(s.ReturnValue, parser.LOWEST)
+		}
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(s)
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expression(s.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(s.Body)
+	case *ast.ForInStatement:
+		p.write("for (")
+		p.write(s.Variable.Value)
+		p.write(" in ")
+		p.expression(s.Iterable, parser.LOWEST)
+		p.write(") ")
+		p.block(s.Body)
+	case *ast.BreakStatement:
+		p.write("break")
+	case *ast.ContinueStatement:
+		p.write("continue")
+	}
+}
+
+/*
+terminate
+文の後にセミコロンを書く
+ブロックで終わる文には書かない
+if式の文は、次の文の先頭が演算子や括弧として続けて読まれてしまう場合だけ区切る
+*/
+func (p *printer) terminate(s ast.Statement, next ast.Statement) {
+	stmt, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		switch s.(type) {
+		case *ast.BlockStatement, *ast.WhileStatement, *ast.ForInStatement:
+		default:
+			p.write(";")
+		}
+		return
+	}
+	if _, isIf := ast.Unparen(stmt.Expression).(*ast.IfExpression); !isIf {
+		p.write(";")
+		return
+	}
+	if next != nil && continuesExpression(next) {
+		p.write(";")
+	}
+}
+
+/*
+continuesExpression
+文を整形したときに、先頭のトークンが前の式に続く中置演算子として読まれるかを返す
+*/
+func continuesExpression(s ast.Statement) bool {
+	sp := &printer{}
+	sp.statement(s)
+	l := lexer.New(sp.buf.String())
+	return parser.Precedence(l.NextToken().Type) > parser.LOWEST
+}
+
+/*
+block
+波括弧で囲んだブロックを書く
+中身は一段深く字下げする
+*/
+func (p *printer) block(b *ast.BlockStatement) {
+	end := b.RBrace.Pos.Offset
+	if !b.RBrace.Pos.IsValid() {
+		end = 0
+	}
+	hasComments := len(p.comments) > 0 && p.comments[0].Pos().Offset < end
+	if len(b.Statements) == 0 && !hasComments {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.depth++
+	p.statements(b.Statements, end, true)
+	p.depth--
+	p.newline(0, false)
+	p.write("}")
+	p.setLine(b.RBrace.Pos.Line)
+}
+
+/*
+precedence
+式を中置演算子の被演算子として置いたときの結合の強さを返す
+*/
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.TokenType(e.Operator))
+	case *ast.LogicalExpression:
+		return parser.Precedence(token.TokenType(e.Operator))
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	case *ast.GroupedExpression:
+		return precedence(ast.Unparen(e))
+	}
+	// リテラルなどはどこに置いても括弧がいらない
+	return parser.INDEX + 1
+}
+
+/*
+expression
+式を書く
+式の結合がprecより弱ければ括弧で囲む
+*/
+func (p *printer) expression(e ast.Expression, prec int) {
+	e = ast.Unparen(e)
+	if precedence(e) < prec {
+		p.write("(")
+		p.expression(e, parser.LOWEST)
+		p.write(")")
+		return
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.String())
+	case *ast.FloatLiteral:
+		p.write(e.String())
+	case *ast.StringLiteral:
+		p.write(e.String())
+	case *ast.Boolean:
+		p.write(e.String())
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expression(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		opPrec := precedence(e)
+		// 左結合なら右側に、右結合なら左側に同じ強さの式があれば括弧が必要になる
+		leftPrec, rightPrec := opPrec, opPrec+1
+		if parser.IsRightAssoc(token.TokenType(e.Operator)) {
+			leftPrec, rightPrec = opPrec+1, opPrec
+		}
+		p.expression(e.Left, leftPrec)
+		p.write(" " + e.Operator + " ")
+		// 前置演算子は右側ならどの強さの位置にも括弧なしで置ける
+		if _, ok := ast.Unparen(e.Right).(*ast.PrefixExpression); ok && rightPrec > parser.PREFIX {
+			rightPrec = parser.PREFIX
+		}
+		p.expression(e.Right, rightPrec)
+	case *ast.LogicalExpression:
+		opPrec := precedence(e)
+		p.expression(e.Left, opPrec)
+		p.write(" " + e.Operator + " ")
+		p.expression(e.Right, opPrec+1)
+	case *ast.AssignExpression:
+		p.expression(e.Target, parser.ASSIGN+1)
+		p.write(" " + e.Operator + " ")
+		// 右結合なので、右側に代入式があっても括弧はいらない
+		p.expression(e.Value, parser.ASSIGN)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.Value)
+		}
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.expression(e.Function, parser.CALL)
+		p.write("(")
+		p.expressionList(e.Arguments)
+		p.write(")")
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.expressionList(e.Elements)
+		p.write("]")
+	case *ast.IndexExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.HashLiteral:
+		p.write("{")
+		for i, pair := range e.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(pair.Key, parser.LOWEST)
+			p.write(": ")
+			p.expression(pair.Value, parser.LOWEST)
+		}
+		p.write("}")
+	default:
+		p.write(e.String())
+	}
+}
+
+func (p *printer) expressionList(list []ast.Expression) {
+	for i, e := range list {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(e, parser.LOWEST)
+	}
+}
@@ -0,0 +1,186 @@
+package format
+
+import (
+	"bytes"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = (a + (b * c));", "let x = a + b * c;\n"},
+		{"let   x=5", "let x = 5;\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"(a + b) * c", "(a + b) * c;\n"},
+		{"a * (b * c)", "a * (b * c);\n"},
+		{"((a)) < (b == c)", "a < (b == c);\n"},
+		{"(a < b) == c", "a < b == c;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"(-a) * b", "-a * b;\n"},
+		{"-(-a)", "--a;\n"},
+		{"!(f(x))", "!f(x);\n"},
+		{"(f)(x)", "f(x);\n"},
+		{"(a + b)(c)", "(a + b)(c);\n"},
+		{"(-f)(x)", "(-f)(x);\n"},
+		{"(a + b)[0]", "(a + b)[0];\n"},
+		{"(f(x))[0]", "f(x)[0];\n"},
+		{"(a[0])(x)", "a[0](x);\n"},
+		{"-(a[0])", "-a[0];\n"},
+		{`[1,2+3,"a\nb"]`, "[1, 2 + 3, \"a\\nb\"];\n"},
+		{`{"a":1,true:(2)}`, "{\"a\": 1, true: 2};\n"},
+		{"{}", "{};\n"},
+		{"fn(){}", "fn() {};\n"},
+		{"fn(x,y){x+y}(1,2)", "fn(x, y) {\n  x + y;\n}(1, 2);\n"},
+		{"return(x)", "return x;\n"},
+		{
+			"let max=fn(a,b){if(a>b){return a}else{b}};",
+			"let max = fn(a, b) {\n  if (a > b) {\n    return a;\n  } else {\n    b;\n  }\n};\n",
+		},
+		{"if (x) { 1 }\nlet y = 2", "if (x) {\n  1;\n}\nlet y = 2;\n"},
+		{"if (x) { 1 }; [1][0]", "if (x) {\n  1;\n};\n[1][0];\n"},
+		{"if (x) { 1 }; -y", "if (x) {\n  1;\n};\n-y;\n"},
+		{"if (x) { 1 }; (a + b) * c", "if (x) {\n  1;\n};\n(a + b) * c;\n"},
+		{"if (x) { 1 }; !y", "if (x) {\n  1;\n}\n!y;\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"let f = fn() {\n  let a = 1;\n\n  a\n};", "let f = fn() {\n  let a = 1;\n\n  a;\n};\n"},
+		{
+			"while(i<10){if(i==5){break}let i=i+1;continue}",
+			"while (i < 10) {\n  if (i == 5) {\n    break;\n  }\n  let i = i + 1;\n  continue;\n}\n",
+		},
+		{"for(x in [1,2]){puts(x)};x", "for (x in [1, 2]) {\n  puts(x);\n}\nx;\n"},
+		{"while (x) {}", "while (x) {}\n"},
+		{"x=y=(1+2)", "x = y = 1 + 2;\n"},
+		{"(x=1)+2", "(x = 1) + 2;\n"},
+		{"a[(i+1)]+=b", "a[i + 1] += b;\n"},
+		{"x=(a==b)", "x = a == b;\n"},
+		{"(a||b)&&c", "(a || b) && c;\n"},
+		{"2**(3**2)", "2 ** 3 ** 2;\n"},
+		{"(0xFF)&0b1010|1_000", "0xFF & 0b1010 | 1_000;\n"},
+		{"(.5)*1E3+(-1.25)", ".5 * 1E3 + -1.25;\n"},
+		{"(2**3)**2", "(2 ** 3) ** 2;\n"},
+		{"(-2)**2", "(-2) ** 2;\n"},
+		{"2**(-1)", "2 ** -1;\n"},
+		{"a*(-b)", "a * -b;\n"},
+		{"(a|b)&~c", "(a | b) & ~c;\n"},
+		{"a-(b%c)<=(d<<1)", "a - b % c <= d << 1;\n"},
+		{"a||(b&&c)", "a || b && c;\n"},
+		{"a&&(b&&c)", "a && (b && c);\n"},
+		{"(a==b)||!c", "a == b || !c;\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		formatted, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("%q: wrong output.\nwant=%q\ngot=%q", tt.input, tt.expected, string(formatted))
+		}
+	}
+}
+
+const program = `
+// fibonacci returns the xth Fibonacci number
+let fibonacci = fn(x) { if (x == 0) { 0 } else { if (x == 1) { return 1; } else { fibonacci(x - 1) + fibonacci(x - 2); } } };
+let map = fn(arr, f) {
+  let iter = fn(arr, accumulated) { if (len(arr) == 0) { accumulated } else { iter(rest(arr), push(accumulated, f(first(arr)))); } };
+
+
+  iter(arr, []);
+};
+let people = [{"name": "Alice", "age": 24}, {"name": "Anna", "age": 28}];
+map(people, fn(p) { p["name"] })[0];
+if ((1 + 2) * 3 > -(4 - 5)) { puts("yes") } /* done */
+-(a - (b - c)) * !(d == (e != f))
+for (p in people) { if (p["age"] > 25) { continue } /* skip */ while (false) { break } total += p["age"]; p["seen"] = (true) }
+`
+
+func TestSourceComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"// header\nlet x=1;   // trailing\n\n\n/* block */\nlet y=2",
+			"// header\nlet x = 1; // trailing\n\n/* block */\nlet y = 2;\n",
+		},
+		{"/* a /* nested */ comment */ x", "/* a /* nested */ comment */\nx;\n"},
+		{"fn() {\n// inside\nx\n}", "fn() {\n  // inside\n  x;\n};\n"},
+		{"if (x) { y; // t\n // end\n }", "if (x) {\n  y; // t\n  // end\n}\n"},
+		{"let f = fn() { /* todo */ };", "let f = fn() {\n  /* todo */\n};\n"},
+		{"f(a, // first\n b);\nz", "f(a, b);\n// first\nz;\n"},
+		{"x;\n// last\n\n// words", "x;\n// last\n\n// words\n"},
+		{"// only a comment", "// only a comment\n"},
+		{"if (x) { y } else { /* nothing */ }", "if (x) {\n  y;\n} else {\n  /* nothing */\n}\n"},
+	}
+	for _, tt := range tests {
+		formatted, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("%q: wrong output.\nwant=%q\ngot=%q", tt.input, tt.expected, string(formatted))
+			continue
+		}
+		again, err := Source("", formatted)
+		if err != nil || string(again) != tt.expected {
+			t.Errorf("%q: formatting is not idempotent. got=%q", tt.input, string(again))
+		}
+	}
+}
+
+func TestSourceIsIdempotent(t *testing.T) {
+	once, err := Source("", []byte(program))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	twice, err := Source("", once)
+	if err != nil {
+		t.Fatalf("formatted source does not parse: %s\n%s", err, once)
+	}
+	if !bytes.Equal(once, twice) {
+		t.Errorf("formatting is not idempotent.\nonce=\n%s\ntwice=\n%s", once, twice)
+	}
+}
+
+func TestSourcePreservesMeaning(t *testing.T) {
+	formatted, err := Source("", []byte(program))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// String()は括弧をすべて付けて出力するので、木の形が同じなら一致する
+	if parse(t, program) != parse(t, string(formatted)) {
+		t.Errorf("formatting changed the program.\n%s", formatted)
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors().Strings())
+	}
+	return program.String()
+}
+
+func TestSourceReportsParseErrors(t *testing.T) {
+	_, err := Source("bad.mk", []byte("let = 1;"))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, ok := err.(parser.ErrorList); !ok {
+		t.Errorf("error not parser.ErrorList. got=%T", err)
+	}
+	if !strings.HasPrefix(err.Error(), "bad.mk:1:5") {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+}
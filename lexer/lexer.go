@@ -1,63 +1,448 @@
 package lexer
 
-import "interpreter/token"
+import (
+	"fmt"
+	"interpreter/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Mode
+字句解析の動作を切り替えるフラグ
+*/
+type Mode uint
+
+const (
+	// ScanComments コメントを読み飛ばさずにCOMMENTトークンとして返す
+	ScanComments Mode = 1 << iota
+)
 
 type Lexer struct {
+	filename     string
 	input        string
-	position     int // current char index
-	readPosition int // next char index
-	ch           byte
+	position     int  // current char index
+	readPosition int  // next char index
+	ch           rune // current char, 0 at EOF
+	invalid      bool // chが不正なUTF-8のバイトを表している
+	line         int  // current char line
+	column       int  // current char column
+	mode         Mode
+	errors       []*Error
+}
+
+/*
+Error
+字句解析のエラー
+ILLEGALトークンを返した理由を表す
+*/
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+/*
+NewFile
+トークンの位置情報にファイル名を含めるLexerを生成する
+*/
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
+	if l.ch == bom {
+		// 先頭のBOMは読み飛ばし、次の文字を1桁目とする
+		l.readChar()
+		l.column = 1
+	}
 	return l
 }
+
+const bom = '\uFEFF'
+
+/*
+SetMode
+字句解析の動作を切り替える
+*/
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+/*
+Errors
+これまでに見つかった字句解析のエラーを返す
+*/
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+func (l *Lexer) error(pos token.Position, msg string) {
+	l.errors = append(l.errors, &Error{Pos: pos, Msg: msg})
+}
+
+/*
+readChar
+次の文字をUTF-8として読む
+列は文字単位で数え、不正なバイトは一バイトを一文字として扱う
+*/
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 1
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.column += 1
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.invalid = false
+	if l.readPosition >= len(l.input) {
+		l.ch = 0
+		l.readPosition += 1
+		return
+	}
+	r, width := rune(l.input[l.readPosition]), 1
+	if r >= utf8.RuneSelf {
+		r, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
+		l.invalid = r == utf8.RuneError && width == 1
+	}
+	l.ch = r
+	l.readPosition += width
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+/*
+currentPosition
+現在の文字の位置を返す
+*/
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'z' || ch == '_'
+/*
+isLetter
+識別子の先頭に置ける文字かを返す
+*/
+func isLetter(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
+}
+
+// 接頭辞の文字ごとの基数と名前
+var numberBases = map[rune]struct {
+	base int
+	name string
+}{
+	'x': {16, "hexadecimal"},
+	'o': {8, "octal"},
+	'b': {2, "binary"},
 }
 
-func (l *Lexer) readNumber() string {
+/*
+readNumber
+数値リテラルを読む
+小数点か指数があればFLOAT、なければINTのトークンを返す
+0x, 0o, 0bの接頭辞付きの整数と、数字の間の区切りの'_'も読む
+0で始まる10進の整数は8進数と紛らわしいので受け付けない
+形式が正しくなければ、問題のある位置のエラーを記録してILLEGALを返す
+*/
+func (l *Lexer) readNumber(pos token.Position) token.Token {
 	position := l.position
-	for isDigit(l.ch) {
+	tokType := token.TokenType(token.INT)
+	if prefix, ok := numberBases[unicode.ToLower(l.peekChar())]; ok && l.ch == '0' {
+		l.readChar()
 		l.readChar()
+		digits := l.position
+		if prefix.base == 16 {
+			l.readDigits(isHexDigit)
+		} else {
+			l.readDigits(isDigit)
+		}
+		literal := l.input[position:l.position]
+		if strings.Trim(l.input[digits:l.position], "_") == "" {
+			l.error(pos, prefix.name+" literal has no digits")
+			return token.Token{Type: token.ILLEGAL, Literal: literal}
+		}
+		for i, ch := range literal[2:] {
+			if ch != '_' && digitValue(ch) >= prefix.base {
+				l.error(offsetPosition(pos, 2+i), fmt.Sprintf("invalid digit %q in %s literal", ch, prefix.name))
+				return token.Token{Type: token.ILLEGAL, Literal: literal}
+			}
+		}
+		return l.checkSeparators(pos, token.Token{Type: tokType, Literal: literal})
 	}
-	return l.input[position:l.position]
+	l.readDigits(isDigit)
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokType = token.FLOAT
+		l.readChar()
+		l.readDigits(isDigit)
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		tokType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.ch) {
+			l.error(pos, "exponent has no digits")
+			tokType = token.ILLEGAL
+		}
+		l.readDigits(isDigit)
+	}
+	tok := token.Token{Type: tokType, Literal: l.input[position:l.position]}
+	if tok.Type == token.ILLEGAL {
+		return tok
+	}
+	if tok.Type == token.INT && len(tok.Literal) > 1 && tok.Literal[0] == '0' {
+		l.error(pos, "leading zeros in decimal integer literals are not allowed; use the 0o prefix for octal")
+		tok.Type = token.ILLEGAL
+		return tok
+	}
+	return l.checkSeparators(pos, tok)
+}
+
+/*
+readDigits
+isDigitを満たす文字と区切りの'_'を読む
+*/
+func (l *Lexer) readDigits(isDigit func(rune) bool) {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+/*
+checkSeparators
+'_'が数字と数字の間にあるかを確かめ、なければILLEGALのトークンを返す
+接頭辞は数字とみなすので、0x_FFのように接頭辞の直後にも置ける
+*/
+func (l *Lexer) checkSeparators(pos token.Position, tok token.Token) token.Token {
+	if i := invalidSeparator(tok.Literal); i >= 0 {
+		l.error(offsetPosition(pos, i), "'_' must separate successive digits")
+		tok.Type = token.ILLEGAL
+	}
+	return tok
 }
 
-func isDigit(ch byte) bool {
+/*
+invalidSeparator
+数字の間にない最初の'_'の位置を返す
+なければ-1を返す
+*/
+func invalidSeparator(literal string) int {
+	hex := false
+	prev := '.' // 直前の文字。数字なら'0'、'_'ならそのまま、それ以外は'.'とする
+	i := 0
+	if len(literal) >= 2 && literal[0] == '0' {
+		if _, ok := numberBases[unicode.ToLower(rune(literal[1]))]; ok {
+			hex = unicode.ToLower(rune(literal[1])) == 'x'
+			prev = '0'
+			i = 2
+		}
+	}
+	for ; i < len(literal); i++ {
+		ch := rune(literal[i])
+		switch {
+		case ch == '_':
+			if prev != '0' {
+				return i
+			}
+		case isDigit(ch) || hex && isHexDigit(ch):
+			ch = '0'
+		default:
+			if prev == '_' {
+				return i - 1
+			}
+			ch = '.'
+		}
+		prev = ch
+	}
+	if prev == '_' {
+		return len(literal) - 1
+	}
+	return -1
+}
+
+/*
+offsetPosition
+posから同じ行でnバイト先の位置を返す
+数値リテラルはASCII文字だけなので、バイト数と文字数は一致する
+*/
+func offsetPosition(pos token.Position, n int) token.Position {
+	pos.Offset += n
+	pos.Column += n
+	return pos
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= unicode.ToLower(ch) && unicode.ToLower(ch) <= 'f'
+}
+
+/*
+digitValue
+16進数までの数字の値を返す
+数字でなければ16を返す
+*/
+func digitValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case isHexDigit(ch):
+		return int(unicode.ToLower(ch)-'a') + 10
+	}
+	return 16
+}
+
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+/*
+readIdentifier
+識別子を読む
+二文字目以降には数字も置ける
+*/
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) peekChar() byte {
+/*
+readString
+ダブルクォートで囲まれた文字列を読み、エスケープシーケンスを展開した値を返す
+閉じられていない文字列や不正なエスケープがあればfalseを返す
+呼び出し後のl.chは閉じるダブルクォートを指す
+*/
+func (l *Lexer) readString() (string, bool) {
+	var out strings.Builder
+	ok := true
+	start := l.currentPosition()
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return out.String(), ok
+		case 0:
+			l.error(start, "unterminated string")
+			return out.String(), false
+		case '\\':
+			escape := l.currentPosition()
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				r, valid := l.readUnicodeEscape()
+				if !valid {
+					ok = false
+					l.error(escape, "invalid unicode escape")
+				}
+				out.WriteRune(r)
+			case 0:
+				l.error(start, "unterminated string")
+				return out.String(), false
+			default:
+				ok = false
+				l.error(escape, fmt.Sprintf("unknown escape sequence \\%c", l.ch))
+				out.WriteRune(l.ch)
+			}
+		default:
+			if l.invalid {
+				ok = false
+				l.error(l.currentPosition(), "invalid UTF-8 encoding in string")
+			}
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+/*
+readUnicodeEscape
+\u{...}形式のエスケープを読み、コードポイントを返す
+呼び出し時のl.chは'u'、呼び出し後は'}'を指す
+*/
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return '\uFFFD', false
+	}
+	l.readChar()
+	position := l.position + 1
+	for l.peekChar() != '}' {
+		if l.peekChar() == 0 || l.peekChar() == '"' {
+			return '\uFFFD', false
+		}
+		l.readChar()
+	}
+	digits := l.input[position:l.readPosition]
+	l.readChar()
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || code > 0x10FFFF || 0xD800 <= code && code <= 0xDFFF {
+		return '\uFFFD', false
+	}
+	return rune(code), true
+}
+
+/*
+readComment
+行コメントは行末まで、ブロックコメントは対応する閉じ記号までを読む
+ブロックコメントは入れ子にでき、閉じられていなければfalseを返す
+呼び出し後のl.chはコメントの直後の文字を指す
+*/
+func (l *Lexer) readComment() (string, bool) {
+	position := l.position
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return strings.TrimRight(l.input[position:l.position], "\r"), true
+	}
+	l.readChar()
+	l.readChar()
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.ch == 0:
+			return l.input[position:l.position], false
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+	}
+	return l.input[position:l.position], true
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -66,9 +451,36 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+/*
+switchToken
+次の文字がnextなら二文字のトークンtwoを、そうでなければ一文字のトークンoneを返す
+二文字のときはnextまで読み進める
+*/
+func (l *Lexer) switchToken(next rune, two, one token.TokenType) token.Token {
+	if l.peekChar() != next {
+		return newToken(one, l.ch)
+	}
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: two, Literal: string(ch) + string(l.ch)}
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespace()
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		pos := l.currentPosition()
+		literal, ok := l.readComment()
+		if !ok {
+			l.error(pos, "unterminated comment")
+			return token.Token{Type: token.ILLEGAL, Literal: literal, Pos: pos, End: l.currentPosition()}
+		}
+		if l.mode&ScanComments != 0 {
+			return token.Token{Type: token.COMMENT, Literal: literal, Pos: pos, End: l.currentPosition()}
+		}
+		l.skipWhitespace()
+	}
+	pos := l.currentPosition()
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -81,6 +493,8 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -89,12 +503,16 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.switchToken('=', token.PLUS_ASSIGN, token.PLUS)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.switchToken('=', token.MINUS_ASSIGN, token.MINUS)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -105,29 +523,71 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLUSH, l.ch)
+		tok = l.switchToken('=', token.SLUSH_ASSIGN, token.SLUSH)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.switchToken('*', token.POWER, token.ASTERISK)
+		} else {
+			tok = l.switchToken('=', token.ASTERISK_ASSIGN, token.ASTERISK)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '&':
+		tok = l.switchToken('&', token.AND, token.AMPERSAND)
+	case '|':
+		tok = l.switchToken('|', token.OR, token.PIPE)
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '<' {
+			tok = l.switchToken('<', token.SHL, token.LT)
+		} else {
+			tok = l.switchToken('=', token.LT_EQ, token.LT)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			tok = l.switchToken('>', token.SHR, token.GT)
+		} else {
+			tok = l.switchToken('=', token.GT_EQ, token.GT)
+		}
+	case '"':
+		literal, ok := l.readString()
+		if ok {
+			tok.Type = token.STRING
+		} else {
+			tok.Type = token.ILLEGAL
+		}
+		tok.Literal = literal
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if l.invalid {
+			l.error(pos, "invalid UTF-8 encoding")
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPosition]}
+		} else if l.ch == bom {
+			l.error(pos, "illegal byte order mark")
+			tok = newToken(token.ILLEGAL, l.ch)
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
+			tok.End = l.currentPosition()
 			return tok
-		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+		} else if isDigit(l.ch) || l.ch == '.' && isDigit(l.peekChar()) {
+			tok = l.readNumber(pos)
+			tok.Pos = pos
+			tok.End = l.currentPosition()
 			return tok
 		} else {
+			l.error(pos, fmt.Sprintf("unexpected character %q", l.ch))
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	tok.Pos = pos
+	tok.End = l.currentPosition()
 	return tok
 }
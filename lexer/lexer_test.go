@@ -13,7 +13,7 @@ let add = fn(x, y) {
   x + y;
 };
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 if (5 < 10) {
   return true;
@@ -69,7 +69,7 @@ if (5 < 10) {
 		{token.IDENT, "ten"},
 		{token.RPAREN, ")"},
 		{token.SEMICOLON, ";"},
-		// !-/*5;
+		// !-/ *5;
 		{token.BANG, "!"},
 		{token.MINUS, "-"},
 		{token.SLUSH, "/"},
@@ -128,3 +128,609 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x == 5"
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{token.IDENT, token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 15, Line: 2, Column: 4}},
+		{token.EQ, token.Position{Offset: 16, Line: 2, Column: 5}, token.Position{Offset: 18, Line: 2, Column: 7}},
+		{token.INT, token.Position{Offset: 19, Line: 2, Column: 8}, token.Position{Offset: 20, Line: 2, Column: 9}},
+		{token.EOF, token.Position{Offset: 20, Line: 2, Column: 9}, token.Position{Offset: 21, Line: 2, Column: 10}},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong, expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong, expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestTokenPositionFilename(t *testing.T) {
+	l := NewFile("script.mk", "\n\n  foo")
+	tok := l.NextToken()
+	if tok.Pos.String() != "script.mk:3:3" {
+		t.Errorf("tok.Pos.String() wrong. got=%q", tok.Pos.String())
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"foobar"`, token.STRING, "foobar"},
+		{`"foo bar"`, token.STRING, "foo bar"},
+		{`""`, token.STRING, ""},
+		{`"a\nb\tc"`, token.STRING, "a\nb\tc"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{48}\u{3042}\u{1F600}"`, token.STRING, "Hあ😀"},
+		{`"unterminated`, token.ILLEGAL, "unterminated"},
+		{`"bad \q escape"`, token.ILLEGAL, "bad q escape"},
+		{`"\u{110000}"`, token.ILLEGAL, "�"},
+		{`"\u{zz}"`, token.ILLEGAL, "�"},
+		{`"\u41"`, token.ILLEGAL, "�41"},
+	}
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Errorf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("tests[%d] - expected EOF after string. got=%q", i, next.Type)
+		}
+	}
+}
+
+func TestStringTokenPosition(t *testing.T) {
+	l := New(`x = "a\nb";`)
+	l.NextToken()
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Pos.Offset != 4 || tok.End.Offset != 10 {
+		t.Errorf("string token range wrong. got=%d-%d", tok.Pos.Offset, tok.End.Offset)
+	}
+	if next := l.NextToken(); next.Type != token.SEMICOLON {
+		t.Errorf("expected SEMICOLON after string. got=%q", next.Type)
+	}
+}
+
+func TestBracketAndColonTokens(t *testing.T) {
+	input := `[1, 2][0];{"a": 1}`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentTokens(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x/ =6 // /=`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLUSH_ASSIGN, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLUSH, "/"},
+		{token.ASSIGN, "="},
+		{token.INT, "6"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalTokens(t *testing.T) {
+	input := `a && b || !c`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.BANG, "!"},
+		{token.IDENT, "c"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestArithmeticAndBitwiseTokens(t *testing.T) {
+	input := `a <= b >= c % d ** e *= f & g | h ^ ~i << j >> k < l > m`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.POWER, "**"},
+		{token.IDENT, "e"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.IDENT, "f"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "g"},
+		{token.PIPE, "|"},
+		{token.IDENT, "h"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENT, "i"},
+		{token.SHL, "<<"},
+		{token.IDENT, "j"},
+		{token.SHR, ">>"},
+		{token.IDENT, "k"},
+		{token.LT, "<"},
+		{token.IDENT, "l"},
+		{token.GT, ">"},
+		{token.IDENT, "m"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `0 42 3.14 .5 1e9 1e-9 2.5E+3 007 1.x 1..2`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, ".5"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.ILLEGAL, "007"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.FLOAT, ".2"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestPrefixedAndSeparatedNumbers(t *testing.T) {
+	input := `0xFF 0XfF 0o755 0O7 0b1010 0B1 1_000_000 0x_dead_beef 1_000.5 1e1_0 07.5 0xFFg`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0xFF"},
+		{token.INT, "0XfF"},
+		{token.INT, "0o755"},
+		{token.INT, "0O7"},
+		{token.INT, "0b1010"},
+		{token.INT, "0B1"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x_dead_beef"},
+		{token.FLOAT, "1_000.5"},
+		{token.FLOAT, "1e1_0"},
+		{token.FLOAT, "07.5"},
+		{token.INT, "0xFF"},
+		{token.IDENT, "g"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+	if errs := l.Errors(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"0x", "0x", "1:1: hexadecimal literal has no digits"},
+		{"x = 0b_;", "0b_", "1:5: binary literal has no digits"},
+		{"0o", "0o", "1:1: octal literal has no digits"},
+		{"0b102", "0b102", "1:5: invalid digit '2' in binary literal"},
+		{"0o19", "0o19", "1:4: invalid digit '9' in octal literal"},
+		{"1__0", "1__0", "1:3: '_' must separate successive digits"},
+		{"a + 1_", "1_", "1:6: '_' must separate successive digits"},
+		{"1_.5", "1_.5", "1:2: '_' must separate successive digits"},
+		{"0x__1", "0x__1", "1:4: '_' must separate successive digits"},
+		{"1e_1", "1e_1", "1:1: exponent has no digits"},
+		{"puts(0777)", "0777", "1:6: leading zeros in decimal integer literals are not allowed; use the 0o prefix for octal"},
+		{"0_1", "0_1", "1:1: leading zeros in decimal integer literals are not allowed; use the 0o prefix for octal"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		var illegal *token.Token
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if tok.Type == token.ILLEGAL {
+				illegal = &tok
+				break
+			}
+		}
+		if illegal == nil {
+			t.Errorf("%q: no ILLEGAL token", tt.input)
+			continue
+		}
+		if illegal.Literal != tt.expectedLiteral {
+			t.Errorf("%q: literal wrong. want=%q, got=%q", tt.input, tt.expectedLiteral, illegal.Literal)
+		}
+		errs := l.Errors()
+		if len(errs) != 1 || errs[0].Error() != tt.expectedError {
+			t.Errorf("%q: errors wrong. want=%q, got=%v", tt.input, tt.expectedError, errs)
+		}
+	}
+}
+
+func TestIdentifierStopsAtBracket(t *testing.T) {
+	l := New("myArray[0]")
+	tok := l.NextToken()
+	if tok.Type != token.IDENT || tok.Literal != "myArray" {
+		t.Fatalf("first token wrong. got=%q(%q)", tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != token.LBRACKET {
+		t.Fatalf("second token not LBRACKET. got=%q", tok.Type)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 1; // trailing
+/* block */ x / 2 /* a /* nested */ comment */
+y`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block */"},
+		{token.IDENT, "x"},
+		{token.SLUSH, "/"},
+		{token.INT, "2"},
+		{token.COMMENT, "/* a /* nested */ comment */"},
+		{token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	l.SetMode(ScanComments)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	// 既定ではコメントを読み飛ばす
+	l = New(input)
+	for i, tt := range tests {
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+	}
+}
+
+func TestCommentPositions(t *testing.T) {
+	l := New("a /* x\ny */ b // z\r\nc")
+	l.SetMode(ScanComments)
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Pos.String() != "1:3" || tok.End.String() != "2:5" {
+		t.Errorf("block comment range wrong. got=%s-%s", tok.Pos, tok.End)
+	}
+	l.NextToken()
+	tok = l.NextToken()
+	if tok.Literal != "// z" {
+		t.Errorf("line comment literal wrong. got=%q", tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Literal != "c" || tok.Pos.Line != 3 {
+		t.Errorf("token after comment wrong. got=%q at %s", tok.Literal, tok.Pos)
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	for _, mode := range []Mode{0, ScanComments} {
+		l := New("x /* open /* nested */")
+		l.SetMode(mode)
+		l.NextToken()
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL || tok.Literal != "/* open /* nested */" {
+			t.Errorf("mode %d: token wrong. got=%q(%q)", mode, tok.Type, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("mode %d: expected EOF after comment. got=%q", mode, next.Type)
+		}
+		errs := l.Errors()
+		if len(errs) != 1 || errs[0].Error() != "1:3: unterminated comment" {
+			t.Errorf("mode %d: errors wrong. got=%v", mode, errs)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc`, "1:1: unterminated string"},
+		{`"a\qb"`, `1:3: unknown escape sequence \q`},
+		{`"\u{zz}"`, "1:2: invalid unicode escape"},
+		{"a @", "1:3: unexpected character '@'"},
+		{"a $ b", "1:3: unexpected character '$'"},
+		{"x + 1e", "1:5: exponent has no digits"},
+		{"1.5e+ 2", "1:1: exponent has no digits"},
+		{"1.", "1:2: unexpected character '.'"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errs := l.Errors()
+		if len(errs) != 1 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: errors wrong. want=%q, got=%v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestUnicodeIdentifiersAndStrings(t *testing.T) {
+	input := `let 名前 = "モンキー";
+let café_2 = 名前 + "さん";
+Δx1`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "名前"},
+		{token.ASSIGN, "="},
+		{token.STRING, "モンキー"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "café_2"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "名前"},
+		{token.PLUS, "+"},
+		{token.STRING, "さん"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "Δx1"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestIdentifierRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"x1", []token.TokenType{token.IDENT}},
+		{"1x", []token.TokenType{token.INT, token.IDENT}},
+		{"_", []token.TokenType{token.IDENT}},
+		{"a١", []token.TokenType{token.IDENT}},
+		{"١", []token.TokenType{token.ILLEGAL}},
+		{"a@b", []token.TokenType{token.IDENT, token.ILLEGAL, token.IDENT}},
+		{"a😀", []token.TokenType{token.IDENT, token.ILLEGAL}},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range append(tt.expected, token.EOF) {
+			tok := l.NextToken()
+			if tok.Type != expected {
+				t.Errorf("%q: tokens[%d] wrong. expected=%q, got=%q(%q)", tt.input, i, expected, tok.Type, tok.Literal)
+				break
+			}
+		}
+	}
+}
+
+func TestUnicodePositions(t *testing.T) {
+	l := NewFile("jp.mk", "let 変数 = \"あい\";\n  変数")
+	tests := []struct {
+		pos string
+		end string
+	}{
+		{"jp.mk:1:1", "jp.mk:1:4"},
+		{"jp.mk:1:5", "jp.mk:1:7"},
+		{"jp.mk:1:8", "jp.mk:1:9"},
+		{"jp.mk:1:10", "jp.mk:1:14"},
+		{"jp.mk:1:14", "jp.mk:1:15"},
+		{"jp.mk:2:3", "jp.mk:2:5"},
+	}
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Pos.String() != tt.pos || tok.End.String() != tt.end {
+			t.Errorf("tests[%d] %q - range wrong. expected=%s-%s, got=%s-%s", i, tok.Literal, tt.pos, tt.end, tok.Pos, tok.End)
+		}
+	}
+	// Offsetはバイト単位のまま
+	l = New("変数 x")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Pos.Offset != 7 {
+		t.Errorf("offset wrong. got=%d", tok.Pos.Offset)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("a \xff b \"c\xfe\"")
+	if tok := l.NextToken(); tok.Type != token.IDENT {
+		t.Fatalf("first token wrong. got=%q", tok.Type)
+	}
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != "\xff" || tok.Pos.String() != "1:3" {
+		t.Errorf("invalid byte token wrong. got=%q(%q) at %s", tok.Type, tok.Literal, tok.Pos)
+	}
+	if tok := l.NextToken(); tok.Type != token.IDENT || tok.Pos.String() != "1:5" {
+		t.Errorf("token after invalid byte wrong. got=%q at %s", tok.Type, tok.Pos)
+	}
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL || tok.Literal != "c\uFFFD" {
+		t.Errorf("string with invalid byte wrong. got=%q(%q)", tok.Type, tok.Literal)
+	}
+	expected := []string{"1:3: invalid UTF-8 encoding", "1:9: invalid UTF-8 encoding in string"}
+	errs := l.Errors()
+	if len(errs) != len(expected) {
+		t.Fatalf("wrong number of errors. got=%v", errs)
+	}
+	for i, e := range expected {
+		if errs[i].Error() != e {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, e, errs[i].Error())
+		}
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	l := New("\uFEFFlet x")
+	tok := l.NextToken()
+	if tok.Type != token.LET || tok.Pos.String() != "1:1" || tok.Pos.Offset != 3 {
+		t.Errorf("token after BOM wrong. got=%q at %s (offset %d)", tok.Type, tok.Pos, tok.Pos.Offset)
+	}
+
+	l = New("x \uFEFF")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Errorf("BOM in the middle not ILLEGAL. got=%q", tok.Type)
+	}
+	if errs := l.Errors(); len(errs) != 1 || errs[0].Error() != "1:3: illegal byte order mark" {
+		t.Errorf("errors wrong. got=%v", errs)
+	}
+}
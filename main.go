@@ -1,18 +1,514 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"interpreter/ast"
+	"interpreter/astjson"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/format"
+	"interpreter/lexer"
+	"interpreter/mkb"
+	"interpreter/object"
+	"interpreter/parser"
 	"interpreter/repl"
+	"interpreter/token"
+	"interpreter/vm"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const usage = `usage: monkey <command> [arguments]
+
+commands:
+  run [-engine=eval|vm] file   run a script (.mk) or compiled bytecode (.mkb)
+  repl [-engine=eval|vm]       start the interactive REPL (default)
+  tokens [-comments] [file]    print the tokens of a script
+  ast [-json] [file]           print the syntax tree of a script
+  check [file...]              report parse errors
+  fmt [-w] [-l] [file...]      format scripts
+  compile [-o out.mkb] file    compile a script to bytecode
+
+A file named "-" or a missing file reads the script from standard input.
+`
+
+// 終了コード
+const (
+	exitOK    = 0 // 成功
+	exitError = 1 // スクリプトにエラーがあった
+	exitUsage = 2 // コマンドの使い方が間違っている
 )
 
 func main() {
-	currentUser, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+/*
+run
+コマンドライン引数に応じてサブコマンドを実行し、終了コードを返す
+サブコマンドがなければREPLを起動する
+*/
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		return replCommand(args, stdin, stdout, stderr)
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "run":
+		return runCommand(args, stdin, stdout, stderr)
+	case "repl":
+		return replCommand(args, stdin, stdout, stderr)
+	case "tokens":
+		return tokensCommand(args, stdin, stdout, stderr)
+	case "ast":
+		return astCommand(args, stdin, stdout, stderr)
+	case "check":
+		return checkCommand(args, stdin, stdout, stderr)
+	case "fmt":
+		return fmtCommand(args, stdin, stdout, stderr)
+	case "compile":
+		return compileCommand(args, stdin, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "monkey: unknown command %q\n", cmd)
+	fmt.Fprint(stderr, usage)
+	return exitUsage
+}
+
+/*
+newFlagSet
+エラーをstderrに書き、終了しないフラグセットを作る
+*/
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("monkey "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	return flags
+}
+
+func engineFlag(flags *flag.FlagSet) *string {
+	return flags.String("engine", repl.EngineEval, "use 'vm' or 'eval'")
+}
+
+func validEngine(engine string, stderr io.Writer) bool {
+	if engine != repl.EngineEval && engine != repl.EngineVM {
+		fmt.Fprintf(stderr, "monkey: unknown engine: %s\n", engine)
+		return false
+	}
+	return true
+}
+
+/*
+readSource
+ファイルを読み込む
+ファイル名が"-"ならstdinから読み込む
+*/
+func readSource(filename string, stdin io.Reader) (string, error) {
+	var (
+		src []byte
+		err error
+	)
+	if filename == "-" {
+		src, err = io.ReadAll(stdin)
+	} else {
+		src, err = os.ReadFile(filename)
+	}
+	return string(src), err
+}
+
+/*
+sourceArg
+ただ一つのファイル名の引数を返す
+省略された場合はstdinを表す"-"を返す
+*/
+func sourceArg(flags *flag.FlagSet, stderr io.Writer) (string, bool) {
+	switch flags.NArg() {
+	case 0:
+		return "-", true
+	case 1:
+		return flags.Arg(0), true
+	}
+	fmt.Fprint(stderr, usage)
+	return "", false
+}
+
+/*
+parseSource
+ファイルを読み込んで構文解析する
+構文エラーがあればstderrに報告してnilを返す
+*/
+func parseSource(filename string, stdin io.Reader, stderr io.Writer) *ast.Program {
+	src, err := readSource(filename, stdin)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, err)
+		return nil
+	}
+	return parseString(displayName(filename), src, stderr)
+}
+
+/*
+parseString
+ソースを構文解析する
+構文エラーがあればstderrに報告してnilを返す
+*/
+func parseString(name, src string, stderr io.Writer) *ast.Program {
+	p := parser.New(lexer.NewFile(name, src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(stderr, program, p.Errors())
+		return nil
+	}
+	return program
+}
+
+/*
+printParseErrors
+構文エラーと、解析できずに読み飛ばした範囲を位置の順に書き出す
+読み飛ばした範囲はその終わりの位置で並べ、原因になったエラーの後に置く
+*/
+func printParseErrors(stderr io.Writer, program *ast.Program, errs parser.ErrorList) {
+	type report struct {
+		pos token.Position
+		msg string
+	}
+	var reports []report
+	for _, err := range errs {
+		reports = append(reports, report{err.Pos, err.Error()})
+	}
+	skipped := func(from, to token.Position, what string) {
+		msg := fmt.Sprintf("%s-%d:%d: skipped invalid %s", from, to.Line, to.Column, what)
+		reports = append(reports, report{to, msg})
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.BadStatement:
+			skipped(n.From, n.To, "statement")
+		case *ast.BadExpression:
+			skipped(n.From, n.To, "expression")
+		}
+		return true
+	})
+	sort.SliceStable(reports, func(i, j int) bool {
+		a, b := reports[i].pos, reports[j].pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	for _, r := range reports {
+		fmt.Fprintln(stderr, r.msg)
+	}
+}
+
+func displayName(filename string) string {
+	if filename == "-" {
+		return "<stdin>"
+	}
+	return filename
+}
+
+func replCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("repl", stderr)
+	engine := engineFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if !validEngine(*engine, stderr) {
+		return exitUsage
+	}
+	if currentUser, err := user.Current(); err == nil {
+		fmt.Fprintf(stdout, "Hello, %s! This is the Monkey programming language!\n", currentUser.Username)
+	}
+	fmt.Fprintf(stdout, "Feel free to type commands\n")
+	repl.Start(stdin, stdout, *engine)
+	return exitOK
+}
+
+/*
+runCommand
+スクリプトを実行する
+.mkbファイルはデコードしてそのままVMで実行する
+*/
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("run", stderr)
+	engine := engineFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if !validEngine(*engine, stderr) {
+		return exitUsage
+	}
+	filename := flags.Arg(0)
+	if filepath.Ext(filename) == mkb.Extension {
+		return runBytecodeFile(filename, stdout, stderr)
+	}
+	program := parseSource(filename, stdin, stderr)
+	if program == nil {
+		return exitError
+	}
+	if *engine == repl.EngineVM {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			fmt.Fprintf(stderr, "compile error: %s\n", err)
+			return exitError
+		}
+		return runBytecode(comp.Bytecode(), false, stdout, stderr)
+	}
+	env := object.NewEnvironment()
+	env.SetOutput(stdout)
+	if errObj, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		fmt.Fprintf(stderr, "runtime error: %s\n", errObj.Message)
+		return exitError
+	}
+	return exitOK
+}
+
+func runBytecodeFile(filename string, stdout, stderr io.Writer) int {
+	f, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer f.Close()
+	bytecode, err := mkb.Decode(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+		return exitError
+	}
+	return runBytecode(bytecode, true, stdout, stderr)
+}
+
+/*
+runBytecode
+バイトコードをVMで実行する
+untrustedならファイルから読んだ命令列として、壊れていてもpanicせずにエラーにする
+*/
+func runBytecode(bytecode *compiler.Bytecode, untrusted bool, stdout, stderr io.Writer) int {
+	machine := vm.New(bytecode)
+	machine.SetOutput(stdout)
+	run := machine.Run
+	if untrusted {
+		run = machine.RunUntrusted
+	}
+	if err := run(); err != nil {
+		fmt.Fprintf(stderr, "runtime error: %s\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+/*
+tokensCommand
+字句解析の結果を一行に一トークンずつ書き出す
+ILLEGALトークンがあれば終了コードで知らせる
+*/
+func tokensCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("tokens", stderr)
+	comments := flags.Bool("comments", false, "print comments as COMMENT tokens")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	filename, ok := sourceArg(flags, stderr)
+	if !ok {
+		return exitUsage
+	}
+	src, err := readSource(filename, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	status := exitOK
+	l := lexer.NewFile(displayName(filename), src)
+	if *comments {
+		l.SetMode(lexer.ScanComments)
+	}
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(stdout, "%s\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
+		if tok.Type == token.ILLEGAL {
+			status = exitError
+		}
+		if tok.Type == token.EOF {
+			return status
+		}
+	}
+}
+
+/*
+astCommand
+構文木を一行に一文ずつ書き出す
+式は括弧で結合の仕方がわかる形で表示される
+-jsonを指定するとastjsonの形式で書き出す
+*/
+func astCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("ast", stderr)
+	asJSON := flags.Bool("json", false, "print the tree as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	filename, ok := sourceArg(flags, stderr)
+	if !ok {
+		return exitUsage
+	}
+	program := parseSource(filename, stdin, stderr)
+	if program == nil {
+		return exitError
+	}
+	if *asJSON {
+		if err := astjson.Encode(stdout, program); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, s := range program.Statements {
+		fmt.Fprintln(stdout, s.String())
+	}
+	return exitOK
+}
+
+/*
+checkCommand
+ファイルを構文解析してエラーを報告する
+一つでもエラーがあれば0以外の終了コードを返す
+*/
+func checkCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("check", stderr)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	filenames := flags.Args()
+	if len(filenames) == 0 {
+		filenames = []string{"-"}
+	}
+	status := exitOK
+	for _, filename := range filenames {
+		if parseSource(filename, stdin, stderr) == nil {
+			status = exitError
+		}
+	}
+	return status
+}
+
+/*
+fmtCommand
+スクリプトを整形する
+-wなら整形結果でファイルを書き換え、-lなら整形で変わるファイルの名前を書き出す
+どちらもなければ整形結果をstdoutに書き出す
+*/
+func fmtCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("fmt", stderr)
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	filenames := flags.Args()
+	if len(filenames) == 0 {
+		filenames = []string{"-"}
+	}
+	status := exitOK
+	for _, filename := range filenames {
+		if *write && filename == "-" {
+			fmt.Fprintln(stderr, "monkey: cannot use -w with standard input")
+			return exitUsage
+		}
+		src, err := readSource(filename, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			status = exitError
+			continue
+		}
+		formatted, err := format.Source(displayName(filename), []byte(src))
+		if err != nil {
+			if _, ok := err.(parser.ErrorList); ok {
+				// 読み飛ばした範囲も示すために、構文木を得るまで解析し直す
+				parseString(displayName(filename), src, stderr)
+			} else {
+				fmt.Fprintln(stderr, err)
+			}
+			status = exitError
+			continue
+		}
+		changed := string(formatted) != src
+		if *list && changed {
+			fmt.Fprintln(stdout, displayName(filename))
+		}
+		if *write && changed {
+			info, err := os.Stat(filename)
+			if err == nil {
+				err = os.WriteFile(filename, formatted, info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				status = exitError
+			}
+		}
+		if !*write && !*list {
+			stdout.Write(formatted)
+		}
+	}
+	return status
+}
+
+/*
+compileCommand
+スクリプトをコンパイルして.mkbファイルに書き出す
+出力先を指定しなければ拡張子を.mkbに変えたファイルに書く
+*/
+func compileCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("compile", stderr)
+	output := flags.String("o", "", "output file")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	filename := flags.Arg(0)
+	out := *output
+	if out == "" {
+		if filename == "-" {
+			fmt.Fprintln(stderr, "monkey: -o is required when compiling standard input")
+			return exitUsage
+		}
+		out = strings.TrimSuffix(filename, filepath.Ext(filename)) + mkb.Extension
+	}
+	program := parseSource(filename, stdin, stderr)
+	if program == nil {
+		return exitError
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(stderr, "compile error: %s\n", err)
+		return exitError
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err := mkb.Encode(f, comp.Bytecode()); err != nil {
+		f.Close()
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
 	}
-	fmt.Printf("Hello, %s! This is the Monkey programming language!\n", currentUser.Username)
-	fmt.Printf("Feel free to type commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	return exitOK
 }
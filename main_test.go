@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/astjson"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileAndRun(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.mk")
+	if err := os.WriteFile(src, []byte(`let name = "monkey"; puts("hello, " + name);`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"compile", src}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("compile exited with %d: %s", code, stderr.String())
+	}
+	out := filepath.Join(dir, "hello.mkb")
+	if _, err := os.Stat(out); err != nil {
+		t.Fatalf("output file not written: %s", err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"run", out}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("run exited with %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "hello, monkey\n" {
+		t.Errorf("output wrong. got=%q", stdout.String())
+	}
+}
+
+func TestCompileOutputFlag(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.mk")
+	if err := os.WriteFile(src, []byte("1 + 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "b.mkb")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"compile", "-o", out, src}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("compile exited with %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Fatalf("output file not written: %s", err)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.mk")
+	os.WriteFile(bad, []byte("let = 1;"), 0o644)
+	corrupt := filepath.Join(dir, "corrupt.mkb")
+	os.WriteFile(corrupt, []byte("not bytecode"), 0o644)
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"compile", bad}, 1, "bad.mk:1:5"},
+		{[]string{"run", corrupt}, 1, "not a monkey bytecode file"},
+		{[]string{"run", bad}, 1, "bad.mk:1:5"},
+		{[]string{"check", bad}, 1, "bad.mk:1:5: expected next token to be IDENT"},
+		{[]string{"ast", bad}, 1, "bad.mk:1:5"},
+		{[]string{"compile"}, 2, "usage:"},
+		{[]string{"run"}, 2, "usage:"},
+		{[]string{"run", "-engine=jit", bad}, 2, "unknown engine: jit"},
+		{[]string{"frobnicate"}, 2, `unknown command "frobnicate"`},
+		{[]string{"check", filepath.Join(dir, "missing.mk")}, 1, "no such file"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, nil, &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v: exit code wrong. want=%d, got=%d", tt.args, tt.code, code)
+		}
+		if !strings.Contains(stderr.String(), tt.expected) {
+			t.Errorf("%v: stderr does not contain %q. got=%q", tt.args, tt.expected, stderr.String())
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "fib.mk")
+	script := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+puts(fib(10));
+`
+	if err := os.WriteFile(src, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []string{"eval", "vm"} {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"run", "-engine=" + engine, src}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: run exited with %d: %s", engine, code, stderr.String())
+		}
+		if stdout.String() != "55\n" {
+			t.Errorf("%s: output wrong. got=%q", engine, stdout.String())
+		}
+	}
+}
+
+func TestEnginesAgree(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected string
+		errors   string
+	}{
+		{"let f = fn() { let x = 0; let inc = fn() { x += 1 }; inc(); x }; puts(f());", "1\n", ""},
+		{"let c = fn() { let n = 0; fn() { n += 1; n } }(); c(); puts(c());", "2\n", ""},
+		{"let x = 1; let f = fn() { x }; let x = 2; puts(f());", "2\n", ""},
+		{"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; puts(f());", "2\n", ""},
+		{"let x = 1; let f = fn() { let x = 10; x }; puts(f()); puts(x);", "10\n1\n", ""},
+		{"let f = fn() { let x = 1; fn() { let x = 5; let y = x; y + x } }; puts(f()());", "10\n", ""},
+		{"let len = fn(a) { 0 }; puts(len([1]));", "0\n", ""},
+		{"let f = fn() { f = 1; f }; puts(f()); puts(f);", "1\n1\n", ""},
+		{"let g = fn() { let f = fn() { f = 2; f }; f() + f }; puts(g());", "4\n", ""},
+		{"let g = fn() { let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5) }; puts(g());", "120\n", ""},
+		{"let x = 1; let r = []; let i = 0; while (i < 2) { let y = x; let x = 5; let r = push(r, y); let i = i + 1; } puts(r);", "[1, 5]\n", ""},
+		// 関数の中で外側の変数を読んだ後に同じ名前を定義すると、評価器とVMで見える変数が変わるので構文エラーにする
+		{"let f = fn() { let x = 1; fn() { let y = x; let x = 5; y + x } }; puts(f()());", "",
+			"<stdin>:1:49: cannot define x after using the outer x in the same function\n"},
+		{"let x = 1; let f = fn() { let r = []; let i = 0; while (i < 2) { let y = x; let x = 5; let r = push(r, y); let i = i + 1; } r }; puts(f());", "",
+			"<stdin>:1:81: cannot define x after using the outer x in the same function\n"},
+		{"let f = fn() { let g = fn() { x }; let x = 5; g() }; puts(f());", "",
+			"<stdin>:1:40: cannot define x after using the outer x in the same function\n"},
+	}
+	for _, tt := range tests {
+		for _, engine := range []string{"eval", "vm"} {
+			var stdout, stderr bytes.Buffer
+			stdin := strings.NewReader(tt.script)
+			code := run([]string{"run", "-engine=" + engine, "-"}, stdin, &stdout, &stderr)
+			if tt.errors == "" && code != 0 {
+				t.Fatalf("%s: %q exited with %d: %s", engine, tt.script, code, stderr.String())
+			}
+			if tt.errors != "" && code == 0 {
+				t.Errorf("%s: %q expected to fail", engine, tt.script)
+			}
+			if stdout.String() != tt.expected {
+				t.Errorf("%s: %q output wrong. want=%q, got=%q", engine, tt.script, tt.expected, stdout.String())
+			}
+			if stderr.String() != tt.errors {
+				t.Errorf("%s: %q errors wrong. want=%q, got=%q", engine, tt.script, tt.errors, stderr.String())
+			}
+		}
+	}
+}
+
+func TestRunScriptRuntimeError(t *testing.T) {
+	for _, engine := range []string{"eval", "vm"} {
+		var stdout, stderr bytes.Buffer
+		stdin := strings.NewReader(`puts("before"); 1 + true; puts("after");`)
+		code := run([]string{"run", "-engine=" + engine, "-"}, stdin, &stdout, &stderr)
+		if code != 1 {
+			t.Errorf("%s: exit code wrong. got=%d", engine, code)
+		}
+		if stdout.String() != "before\n" {
+			t.Errorf("%s: stdout wrong. got=%q", engine, stdout.String())
+		}
+		if stderr.String() != "runtime error: type mismatch: INTEGER + BOOLEAN\n" {
+			t.Errorf("%s: stderr wrong. got=%q", engine, stderr.String())
+		}
+	}
+}
+
+func TestTokensCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"tokens"}, strings.NewReader("let x = 5;"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("tokens exited with %d: %s", code, stderr.String())
+	}
+	expected := `<stdin>:1:1	LET	"let"
+<stdin>:1:5	IDENT	"x"
+<stdin>:1:7	=	"="
+<stdin>:1:9	INT	"5"
+<stdin>:1:10	;	";"
+<stdin>:1:11	EOF	""
+`
+	if stdout.String() != expected {
+		t.Errorf("output wrong.\nwant=%q\ngot=%q", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	code = run([]string{"tokens", "-comments"}, strings.NewReader("x // note"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("tokens exited with %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "<stdin>:1:3\tCOMMENT\t\"// note\"\n") {
+		t.Errorf("comment token missing. got=%q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"tokens", "-"}, strings.NewReader(`"abc`), &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 for ILLEGAL token. got=%d", code)
+	}
+}
+
+func TestASTCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"ast"}, strings.NewReader("let x = 1 + 2 * 3;\n-a == b"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("ast exited with %d: %s", code, stderr.String())
+	}
+	expected := "let x = (1 + (2 * 3));\n((-a) == b)\n"
+	if stdout.String() != expected {
+		t.Errorf("output wrong.\nwant=%q\ngot=%q", expected, stdout.String())
+	}
+}
+
+func TestASTCommandJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"ast", "-json"}, strings.NewReader("(x)"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("ast exited with %d: %s", code, stderr.String())
+	}
+	node, err := astjson.Unmarshal(stdout.Bytes())
+	if err != nil {
+		t.Fatalf("output is not an AST: %s", err)
+	}
+	stmt := node.(*ast.Program).Statements[0].(*ast.ExpressionStatement)
+	if _, ok := stmt.Expression.(*ast.GroupedExpression); !ok {
+		t.Errorf("expression not *ast.GroupedExpression. got=%T", stmt.Expression)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.mk")
+	os.WriteFile(good, []byte("let x = 1;"), 0o644)
+	bad := filepath.Join(dir, "bad.mk")
+	os.WriteFile(bad, []byte("let x 1;\nlet = 2;"), 0o644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"check", good}, nil, &stdout, &stderr); code != 0 {
+		t.Errorf("check of valid file exited with %d: %s", code, stderr.String())
+	}
+	if code := run([]string{"check", good, bad}, nil, &stdout, &stderr); code != 1 {
+		t.Errorf("check of invalid file exited with %d", code)
+	}
+	expected := []string{
+		bad + ":1:7: expected next token to be =, got INT instead",
+		bad + ":1:1-1:9: skipped invalid statement",
+		bad + ":2:5: expected next token to be IDENT, got = instead",
+		bad + ":2:1-2:9: skipped invalid statement",
+	}
+	if stderr.String() != strings.Join(expected, "\n")+"\n" {
+		t.Errorf("errors wrong. got=%q", stderr.String())
+	}
+}
+
+func TestReplCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"repl", "-engine=vm"}, strings.NewReader("1 + 2\n"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("repl exited with %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), ">> 3\n") {
+		t.Errorf("output wrong. got=%q", stdout.String())
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.mk")
+	os.WriteFile(messy, []byte("let x=(1+2)*3;puts( x )"), 0o644)
+	tidy := filepath.Join(dir, "tidy.mk")
+	os.WriteFile(tidy, []byte("let y = 1;\n"), 0o644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "-l", messy, tidy}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt -l exited with %d: %s", code, stderr.String())
+	}
+	if stdout.String() != messy+"\n" {
+		t.Errorf("fmt -l output wrong. got=%q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"fmt", "-w", messy}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt -w exited with %d: %s", code, stderr.String())
+	}
+	got, _ := os.ReadFile(messy)
+	if string(got) != "let x = (1 + 2) * 3;\nputs(x);\n" {
+		t.Errorf("file not formatted. got=%q", got)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("fmt -w wrote to stdout: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"fmt"}, strings.NewReader("[1,2]"), &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt exited with %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "[1, 2];\n" {
+		t.Errorf("fmt output wrong. got=%q", stdout.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"fmt"}, strings.NewReader("let = 1"), &stdout, &stderr); code != 1 {
+		t.Errorf("fmt of invalid source exited with %d", code)
+	}
+	if !strings.HasPrefix(stderr.String(), "<stdin>:1:5:") {
+		t.Errorf("fmt error wrong. got=%q", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"fmt"}, strings.NewReader("puts(1, 2;"), &stdout, &stderr); code != 1 {
+		t.Errorf("fmt of unclosed call exited with %d", code)
+	}
+	if !strings.HasSuffix(stderr.String(), "\n<stdin>:1:1-1:10: skipped invalid expression\n") {
+		t.Errorf("fmt error does not show the skipped span. got=%q", stderr.String())
+	}
+}
@@ -0,0 +1,449 @@
+/*
+Package mkb
+コンパイル済みのバイトコードを.mkbファイルとして読み書きする
+
+ファイルの形式(数値はすべてビッグエンディアン)
+
+	magic    [4]byte  "MKB\x00"
+	version  uint16
+	length   uint32   payloadのバイト数
+	payload  [length]byte
+	checksum uint32   magicからpayloadまでのCRC-32(IEEE)
+
+payloadは定数の数(uint32)、各定数、メインの命令列の順に並ぶ
+定数は種類を表す1バイトのタグに続けて値を書く
+*/
+package mkb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/object"
+	"io"
+	"math"
+)
+
+// Extension .mkbファイルの拡張子
+const Extension = ".mkb"
+
+// Version 現在の形式のバージョン
+// 命令や定数の種類を増やしたら上げ、opcodeVersionsかtagVersionsに記録する
+// 古いバージョンのファイルは新しい形式の部分集合なのでそのまま読み込める
+const Version uint16 = 5
+
+/*
+opcodeVersions
+バージョン1より後に加えた命令と、それを加えたバージョン
+命令は常に末尾に加えるので、古いバージョンの命令の番号は変わらない
+*/
+var opcodeVersions = map[code.Opcode]uint16{
+	code.OpIter:         2,
+	code.OpIterNext:     2,
+	code.OpSetFree:      3,
+	code.OpSetIndex:     3,
+	code.OpDup2:         3,
+	code.OpGetLocalCell: 3,
+	code.OpGetFreeCell:  3,
+	code.OpMod:          4,
+	code.OpPow:          4,
+	code.OpBitAnd:       4,
+	code.OpBitOr:        4,
+	code.OpBitXor:       4,
+	code.OpShiftLeft:    4,
+	code.OpShiftRight:   4,
+	code.OpLessEqual:    4,
+	code.OpGreaterEqual: 4,
+	code.OpBitNot:       4,
+}
+
+var magic = [4]byte{'M', 'K', 'B', 0}
+
+const headerSize = 4 + 2 + 4
+
+var (
+	// ErrBadMagic ファイルの先頭が.mkbの形式ではない
+	ErrBadMagic = errors.New("mkb: not a monkey bytecode file")
+	// ErrVersionMismatch 読み込めないバージョンで書かれている
+	ErrVersionMismatch = errors.New("mkb: unsupported format version")
+	// ErrChecksum チェックサムが一致しない
+	ErrChecksum = errors.New("mkb: checksum mismatch")
+	// ErrCorrupt 内容が壊れている
+	ErrCorrupt = errors.New("mkb: corrupt file")
+)
+
+// 定数の種類を表すタグ
+const (
+	tagInteger byte = iota + 1
+	tagBoolean
+	tagString
+	tagNull
+	tagCompiledFunction
+	tagFloat
+)
+
+// tagVersions バージョン1より後に加えた定数の種類と、それを加えたバージョン
+var tagVersions = map[byte]uint16{
+	tagFloat: 5,
+}
+
+/*
+Encode
+バイトコードを.mkb形式でwに書き込む
+*/
+func Encode(w io.Writer, bc *compiler.Bytecode) error {
+	var payload bytes.Buffer
+	writeUint32(&payload, uint32(len(bc.Constants)))
+	for i, c := range bc.Constants {
+		if err := encodeConstant(&payload, c); err != nil {
+			return fmt.Errorf("mkb: constant %d: %w", i, err)
+		}
+	}
+	writeBytes(&payload, bc.Instructions)
+
+	var out bytes.Buffer
+	out.Write(magic[:])
+	writeUint16(&out, Version)
+	writeUint32(&out, uint32(payload.Len()))
+	out.Write(payload.Bytes())
+	writeUint32(&out, crc32.ChecksumIEEE(out.Bytes()))
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func encodeConstant(buf *bytes.Buffer, obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		buf.WriteByte(tagInteger)
+		writeUint64(buf, uint64(obj.Value))
+	case *object.Float:
+		buf.WriteByte(tagFloat)
+		writeUint64(buf, math.Float64bits(obj.Value))
+	case *object.Boolean:
+		buf.WriteByte(tagBoolean)
+		if obj.Value {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case *object.String:
+		buf.WriteByte(tagString)
+		writeBytes(buf, []byte(obj.Value))
+	case *object.Null:
+		buf.WriteByte(tagNull)
+	case *object.CompiledFunction:
+		buf.WriteByte(tagCompiledFunction)
+		writeUint32(buf, uint32(obj.NumLocals))
+		writeUint32(buf, uint32(obj.NumParameters))
+		writeBytes(buf, obj.Instructions)
+	default:
+		return fmt.Errorf("cannot encode %s", obj.Type())
+	}
+	return nil
+}
+
+/*
+Decode
+.mkb形式のバイトコードをrから読み込む
+形式、バージョン、チェックサムが合わなければエラーを返す
+Versionより古いバージョンのファイルも読み込めるが、そのバージョンになかった命令や定数があれば壊れたファイルとして扱う
+*/
+func Decode(r io.Reader) (*compiler.Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(magic) || !bytes.Equal(data[:len(magic)], magic[:]) {
+		return nil, ErrBadMagic
+	}
+	if len(data) < headerSize {
+		return nil, ErrCorrupt
+	}
+	version := binary.BigEndian.Uint16(data[4:])
+	if version == 0 || version > Version {
+		return nil, fmt.Errorf("%w: got %d, want at most %d", ErrVersionMismatch, version, Version)
+	}
+	length := binary.BigEndian.Uint32(data[6:])
+	if uint64(len(data)) != uint64(headerSize)+uint64(length)+4 {
+		return nil, ErrCorrupt
+	}
+	body := data[:headerSize+int(length)]
+	checksum := binary.BigEndian.Uint32(data[headerSize+int(length):])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, ErrChecksum
+	}
+
+	d := &decoder{data: data[headerSize : headerSize+int(length)], version: version}
+	numConstants := d.uint32()
+	constants := []object.Object{}
+	for i := uint32(0); i < numConstants && d.err == nil; i++ {
+		constants = append(constants, d.constant())
+	}
+	instructions := d.bytes()
+	if d.err == nil && d.pos != len(d.data) {
+		d.err = ErrCorrupt
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	bc := &compiler.Bytecode{Instructions: instructions, Constants: constants}
+	if err := verify(bc, version); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+/*
+verify
+命令列がversionで定義された命令だけからなり、定数、ローカル変数、自由変数の参照が範囲内にあることを確かめる
+*/
+func verify(bc *compiler.Bytecode, version uint16) error {
+	main := &object.CompiledFunction{Instructions: bc.Instructions}
+	functions := []*object.CompiledFunction{main}
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			if fn.NumParameters > fn.NumLocals {
+				return fmt.Errorf("%w: function has %d parameters but %d locals", ErrCorrupt, fn.NumParameters, fn.NumLocals)
+			}
+			functions = append(functions, fn)
+		}
+	}
+	for _, fn := range functions {
+		if err := verifyInstructions(fn.Instructions, bc.Constants, version); err != nil {
+			return err
+		}
+	}
+	numFree := closureFreeCounts(functions, bc.Constants)
+	for _, fn := range functions {
+		if err := verifyVariables(fn, numFree[fn]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyInstructions(ins code.Instructions, constants []object.Object, version uint16) error {
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return fmt.Errorf("%w: %s at %d", ErrCorrupt, err, i)
+		}
+		if since, ok := opcodeVersions[code.Opcode(ins[i])]; ok && version < since {
+			return fmt.Errorf("%w: %s is not in version %d at %d", ErrCorrupt, def.Name, version, i)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			return fmt.Errorf("%w: truncated %s at %d", ErrCorrupt, def.Name, i)
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		switch code.Opcode(ins[i]) {
+		case code.OpConstant:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("%w: constant %d out of range at %d", ErrCorrupt, operands[0], i)
+			}
+		case code.OpClosure:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("%w: constant %d out of range at %d", ErrCorrupt, operands[0], i)
+			}
+			if _, ok := constants[operands[0]].(*object.CompiledFunction); !ok {
+				return fmt.Errorf("%w: constant %d is not a function at %d", ErrCorrupt, operands[0], i)
+			}
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+			if operands[0] > len(ins) {
+				return fmt.Errorf("%w: jump target %d out of range at %d", ErrCorrupt, operands[0], i)
+			}
+		case code.OpGetBuiltin:
+			if operands[0] >= len(object.Builtins) {
+				return fmt.Errorf("%w: builtin %d out of range at %d", ErrCorrupt, operands[0], i)
+			}
+		}
+		i += 1 + read
+	}
+	return nil
+}
+
+/*
+closureFreeCounts
+関数ごとに、OpClosureで捕捉される自由変数の数を求める
+複数のOpClosureから作られる関数は最も少ない数を、一度も作られない関数は0とする
+命令列はverifyInstructionsで検証済みであること
+*/
+func closureFreeCounts(functions []*object.CompiledFunction, constants []object.Object) map[*object.CompiledFunction]int {
+	counts := make(map[*object.CompiledFunction]int)
+	for _, fn := range functions {
+		forEachInstruction(fn.Instructions, func(op code.Opcode, operands []int) error {
+			if op != code.OpClosure {
+				return nil
+			}
+			closed := constants[operands[0]].(*object.CompiledFunction)
+			if n, ok := counts[closed]; !ok || operands[1] < n {
+				counts[closed] = operands[1]
+			}
+			return nil
+		})
+	}
+	return counts
+}
+
+/*
+verifyVariables
+ローカル変数と自由変数の番号が、関数の持つ数の範囲内にあることを確かめる
+命令列はverifyInstructionsで検証済みであること
+*/
+func verifyVariables(fn *object.CompiledFunction, numFree int) error {
+	return forEachInstruction(fn.Instructions, func(op code.Opcode, operands []int) error {
+		switch op {
+		case code.OpGetLocal, code.OpSetLocal, code.OpGetLocalCell:
+			if operands[0] >= fn.NumLocals {
+				return fmt.Errorf("%w: local %d out of range", ErrCorrupt, operands[0])
+			}
+		case code.OpGetFree, code.OpSetFree, code.OpGetFreeCell:
+			if operands[0] >= numFree {
+				return fmt.Errorf("%w: free variable %d out of range", ErrCorrupt, operands[0])
+			}
+		}
+		return nil
+	})
+}
+
+/*
+forEachInstruction
+命令列の命令を先頭から順にfに渡す
+fがエラーを返したらそこで止め、そのエラーを返す
+*/
+func forEachInstruction(ins code.Instructions, f func(op code.Opcode, operands []int) error) error {
+	for i := 0; i < len(ins); {
+		def, _ := code.Lookup(ins[i])
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		if err := f(code.Opcode(ins[i]), operands); err != nil {
+			return err
+		}
+		i += 1 + read
+	}
+	return nil
+}
+
+/*
+decoder
+payloadを先頭から読む
+途中で壊れた箇所を見つけたらerrを設定し、以降はゼロ値を返す
+*/
+type decoder struct {
+	data    []byte
+	pos     int
+	err     error
+	version uint16
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.data)-d.pos < n {
+		d.err = ErrCorrupt
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.read(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint32()
+	if uint64(n) > uint64(len(d.data)) {
+		d.err = ErrCorrupt
+		return nil
+	}
+	b := d.read(int(n))
+	if b == nil {
+		return nil
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
+
+func (d *decoder) constant() object.Object {
+	tag := d.byte()
+	if since, ok := tagVersions[tag]; ok && d.version < since && d.err == nil {
+		d.err = fmt.Errorf("%w: constant tag %d is not in version %d", ErrCorrupt, tag, d.version)
+		return nil
+	}
+	switch tag {
+	case tagInteger:
+		return &object.Integer{Value: int64(d.uint64())}
+	case tagFloat:
+		return &object.Float{Value: math.Float64frombits(d.uint64())}
+	case tagBoolean:
+		return object.NativeBoolToBooleanObject(d.byte() != 0)
+	case tagString:
+		return &object.String{Value: string(d.bytes())}
+	case tagNull:
+		return object.NULL
+	case tagCompiledFunction:
+		numLocals := d.uint32()
+		numParameters := d.uint32()
+		instructions := d.bytes()
+		return &object.CompiledFunction{
+			Instructions:  code.Instructions(instructions),
+			NumLocals:     int(numLocals),
+			NumParameters: int(numParameters),
+		}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: unknown constant tag %d", ErrCorrupt, tag)
+		}
+		return nil
+	}
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUint32(buf, uint32(len(b)))
+	buf.Write(b)
+}
@@ -0,0 +1,255 @@
+package mkb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/vm"
+	"testing"
+)
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors().Strings())
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return comp.Bytecode()
+}
+
+func encode(t *testing.T, bc *compiler.Bytecode) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, bc); err != nil {
+		t.Fatalf("Encode failed: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	input := `
+let greet = fn(name) { "hello, " + name };
+let adder = fn(x) { fn(y) { x + y } };
+let flag = if (true) { false };
+let none = if (false) { 1 };
+puts(greet("monkey"));
+[adder(40)(2), flag, none, {"k": -7}, 0.5 * 3]
+`
+	bc := compile(t, input)
+	decoded, err := Decode(bytes.NewReader(encode(t, bc)))
+	if err != nil {
+		t.Fatalf("Decode failed: %s", err)
+	}
+	if decoded.Instructions.String() != bc.Instructions.String() {
+		t.Errorf("instructions wrong.\nwant=%q\ngot=%q", bc.Instructions.String(), decoded.Instructions.String())
+	}
+	if len(decoded.Constants) != len(bc.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(bc.Constants), len(decoded.Constants))
+	}
+	for i, c := range bc.Constants {
+		if decoded.Constants[i].Type() != c.Type() {
+			t.Errorf("constant %d type wrong. want=%s, got=%s", i, c.Type(), decoded.Constants[i].Type())
+		}
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			got := decoded.Constants[i].(*object.CompiledFunction)
+			if got.Instructions.String() != fn.Instructions.String() ||
+				got.NumLocals != fn.NumLocals || got.NumParameters != fn.NumParameters {
+				t.Errorf("constant %d function wrong. want=%+v, got=%+v", i, fn, got)
+			}
+		} else if decoded.Constants[i].Inspect() != c.Inspect() {
+			t.Errorf("constant %d wrong. want=%s, got=%s", i, c.Inspect(), decoded.Constants[i].Inspect())
+		}
+	}
+
+	var out bytes.Buffer
+	machine := vm.New(decoded)
+	machine.SetOutput(&out)
+	if err := machine.RunUntrusted(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if out.String() != "hello, monkey\n" {
+		t.Errorf("output wrong. got=%q", out.String())
+	}
+	result := machine.LastPoppedStackElem().Inspect()
+	if result != "[42, false, null, {k: -7}, 1.5]" {
+		t.Errorf("result wrong. got=%s", result)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := encode(t, compile(t, "let x = 1; x + 2"))
+
+	modify := func(f func(b []byte) []byte) []byte {
+		b := append([]byte(nil), valid...)
+		return f(b)
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{"empty", nil, ErrBadMagic},
+		{"bad magic", modify(func(b []byte) []byte { b[0] = 'X'; return b }), ErrBadMagic},
+		{"version", modify(func(b []byte) []byte { b[5]++; return b }), ErrVersionMismatch},
+		{"version zero", modify(func(b []byte) []byte { b[4], b[5] = 0, 0; return b }), ErrVersionMismatch},
+		{"flipped byte", modify(func(b []byte) []byte { b[headerSize] ^= 0xff; return b }), ErrChecksum},
+		{"truncated", valid[:len(valid)-6], ErrCorrupt},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.input))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: wrong error. want=%v, got=%v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func instructions(ins ...[]byte) code.Instructions {
+	var out code.Instructions
+	for _, in := range ins {
+		out = append(out, in...)
+	}
+	return out
+}
+
+func TestDecodeRejectsBadOperands(t *testing.T) {
+	callFn := func(fn *object.CompiledFunction, numFree int) *compiler.Bytecode {
+		var main code.Instructions
+		for i := 0; i < numFree; i++ {
+			main = append(main, code.Make(code.OpNull)...)
+		}
+		main = append(main, instructions(
+			code.Make(code.OpClosure, 0, numFree),
+			code.Make(code.OpCall, 0),
+			code.Make(code.OpPop),
+		)...)
+		return &compiler.Bytecode{Instructions: main, Constants: []object.Object{fn}}
+	}
+	tests := []struct {
+		name string
+		bc   *compiler.Bytecode
+	}{
+		{"local out of range", callFn(&object.CompiledFunction{
+			Instructions: instructions(code.Make(code.OpGetLocal, 5), code.Make(code.OpReturnValue)),
+			NumLocals:    1,
+		}, 0)},
+		{"set local out of range", callFn(&object.CompiledFunction{
+			Instructions: instructions(code.Make(code.OpNull), code.Make(code.OpSetLocal, 0), code.Make(code.OpReturn)),
+		}, 0)},
+		{"local in main", &compiler.Bytecode{
+			Instructions: instructions(code.Make(code.OpGetLocal, 0), code.Make(code.OpPop)),
+		}},
+		{"free out of range", callFn(&object.CompiledFunction{
+			Instructions: instructions(code.Make(code.OpGetFree, 1), code.Make(code.OpReturnValue)),
+		}, 1)},
+		{"more parameters than locals", callFn(&object.CompiledFunction{
+			Instructions:  instructions(code.Make(code.OpReturn)),
+			NumParameters: 2,
+		}, 0)},
+		{"iterator jump out of range", &compiler.Bytecode{
+			Instructions: instructions(code.Make(code.OpIterNext, 500)),
+		}},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(encode(t, tt.bc)))
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: wrong error. want=%v, got=%v", tt.name, ErrCorrupt, err)
+		}
+	}
+}
+
+func TestRunCraftedFile(t *testing.T) {
+	tests := []code.Instructions{
+		instructions(code.Make(code.OpPop)),
+		instructions(code.Make(code.OpAdd)),
+		instructions(code.Make(code.OpCall, 200)),
+		instructions(code.Make(code.OpReturnValue)),
+	}
+	for _, ins := range tests {
+		bc, err := Decode(bytes.NewReader(encode(t, &compiler.Bytecode{Instructions: ins})))
+		if err != nil {
+			t.Fatalf("Decode failed: %s", err)
+		}
+		if err := vm.New(bc).RunUntrusted(); err == nil {
+			t.Errorf("%q: expected error", ins.String())
+		}
+	}
+}
+
+// version1Fixture `let x = 40; puts(x + 2)`をバージョン1の形式で書いたもの
+var version1Fixture = []byte{
+	0x4d, 0x4b, 0x42, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00,
+	0x00, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x28, 0x01,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x12,
+	0x00, 0x00, 0x00, 0x11, 0x00, 0x00, 0x1a, 0x01, 0x10, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x01, 0x15, 0x01, 0x05, 0x72, 0x45, 0xd8, 0x7f,
+}
+
+func TestDecodeOlderVersion(t *testing.T) {
+	bc, err := Decode(bytes.NewReader(version1Fixture))
+	if err != nil {
+		t.Fatalf("Decode failed: %s", err)
+	}
+	var out bytes.Buffer
+	machine := vm.New(bc)
+	machine.SetOutput(&out)
+	if err := machine.RunUntrusted(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if out.String() != "42\n" {
+		t.Errorf("output wrong. got=%q", out.String())
+	}
+}
+
+/*
+withVersion
+.mkbファイルのバージョンを書き換え、チェックサムを計算し直す
+*/
+func withVersion(b []byte, version uint16) []byte {
+	out := append([]byte(nil), b...)
+	binary.BigEndian.PutUint16(out[4:], version)
+	body := out[:len(out)-4]
+	binary.BigEndian.PutUint32(out[len(out)-4:], crc32.ChecksumIEEE(body))
+	return out
+}
+
+func TestDecodeRejectsNewerFeatures(t *testing.T) {
+	tests := []struct {
+		input   string
+		version uint16
+	}{
+		{"for (x in 3) { x }", 1},
+		{"let a = [1]; a[0] = 2", 2},
+		{"fn(x) { fn() { x } }", 2},
+		{"5 % 2", 3},
+		{"1.5", 4},
+	}
+	for _, tt := range tests {
+		b := encode(t, compile(t, tt.input))
+		if _, err := Decode(bytes.NewReader(withVersion(b, tt.version))); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%q: version %d: wrong error. want=%v, got=%v", tt.input, tt.version, ErrCorrupt, err)
+		}
+		if _, err := Decode(bytes.NewReader(withVersion(b, tt.version+1))); err != nil {
+			t.Errorf("%q: version %d: Decode failed: %s", tt.input, tt.version+1, err)
+		}
+	}
+}
+
+func TestEncodeRejectsUnknownConstant(t *testing.T) {
+	bc := &compiler.Bytecode{Constants: []object.Object{&object.Array{}}}
+	var buf bytes.Buffer
+	if err := Encode(&buf, bc); err == nil {
+		t.Fatalf("expected error for unsupported constant")
+	}
+}
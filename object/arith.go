@@ -0,0 +1,33 @@
+package object
+
+/*
+IntPow
+整数のべき乗を二乗を繰り返して計算する
+expは0以上でなければならず、桁あふれは他の整数演算と同じく切り捨てられる
+*/
+func IntPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+/*
+FloatValue
+整数か浮動小数点数の値をfloat64として返す
+数値でなければfalseを返す
+*/
+func FloatValue(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,185 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+/*
+BuiltinFunction
+Goで実装された組み込み関数の型
+outはputsなどの出力先
+*/
+type BuiltinFunction func(out io.Writer, args ...Object) Object
+
+/*
+Builtin
+組み込み関数を値として扱うための型
+*/
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function " + b.Name
+}
+
+/*
+Builtins
+名前で引ける組み込み関数の一覧
+並び順は組み込み関数の番号として使うので、追加は末尾に行う
+*/
+var Builtins = []*Builtin{
+	{Name: "len", Fn: builtinLen},
+	{Name: "puts", Fn: builtinPuts},
+	{Name: "first", Fn: builtinFirst},
+	{Name: "last", Fn: builtinLast},
+	{Name: "rest", Fn: builtinRest},
+	{Name: "push", Fn: builtinPush},
+}
+
+/*
+GetBuiltinByName
+名前に対応する組み込み関数を返す
+見つからなければnilを返す
+*/
+func GetBuiltinByName(name string) *Builtin {
+	for _, b := range Builtins {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func wrongNumberOfArguments(want int, args []Object) *Error {
+	return newError("wrong number of arguments: want=%d, got=%d", want, len(args))
+}
+
+/*
+builtinLen
+文字列の文字数、配列の要素数、ハッシュのキーの数を返す
+*/
+func builtinLen(out io.Writer, args ...Object) Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(1, args)
+	}
+	switch arg := args[0].(type) {
+	case *String:
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Hash:
+		return &Integer{Value: int64(len(arg.Pairs))}
+	default:
+		return newError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+/*
+builtinPuts
+引数をそれぞれ一行ずつ出力する
+*/
+func builtinPuts(out io.Writer, args ...Object) Object {
+	for _, arg := range args {
+		fmt.Fprintln(out, arg.Inspect())
+	}
+	return NULL
+}
+
+/*
+arrayArgument
+引数が配列一つであることを確かめる
+*/
+func arrayArgument(name string, args []Object) (*Array, *Error) {
+	if len(args) != 1 {
+		return nil, wrongNumberOfArguments(1, args)
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	return arr, nil
+}
+
+/*
+builtinFirst
+配列の最初の要素を返す
+空の配列にはNULLを返す
+*/
+func builtinFirst(out io.Writer, args ...Object) Object {
+	arr, err := arrayArgument("first", args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return NULL
+	}
+	return arr.Elements[0]
+}
+
+/*
+builtinLast
+配列の最後の要素を返す
+空の配列にはNULLを返す
+*/
+func builtinLast(out io.Writer, args ...Object) Object {
+	arr, err := arrayArgument("last", args)
+	if err != nil {
+		return err
+	}
+	length := len(arr.Elements)
+	if length == 0 {
+		return NULL
+	}
+	return arr.Elements[length-1]
+}
+
+/*
+builtinRest
+最初の要素を除いた新しい配列を返す
+空の配列にはNULLを返す
+*/
+func builtinRest(out io.Writer, args ...Object) Object {
+	arr, err := arrayArgument("rest", args)
+	if err != nil {
+		return err
+	}
+	length := len(arr.Elements)
+	if length == 0 {
+		return NULL
+	}
+	newElements := make([]Object, length-1)
+	copy(newElements, arr.Elements[1:length])
+	return &Array{Elements: newElements}
+}
+
+/*
+builtinPush
+末尾に要素を加えた新しい配列を返す
+元の配列は変更しない
+*/
+func builtinPush(out io.Writer, args ...Object) Object {
+	if len(args) != 2 {
+		return wrongNumberOfArguments(2, args)
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+	}
+	length := len(arr.Elements)
+	newElements := make([]Object, length+1)
+	copy(newElements, arr.Elements)
+	newElements[length] = args[1]
+	return &Array{Elements: newElements}
+}
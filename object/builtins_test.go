@@ -0,0 +1,44 @@
+package object
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetBuiltinByName(t *testing.T) {
+	for _, name := range []string{"len", "puts", "first", "last", "rest", "push"} {
+		b := GetBuiltinByName(name)
+		if b == nil {
+			t.Errorf("builtin %q not found", name)
+			continue
+		}
+		if b.Name != name {
+			t.Errorf("builtin has wrong name. expected=%q, got=%q", name, b.Name)
+		}
+	}
+	if GetBuiltinByName("nope") != nil {
+		t.Errorf("unknown builtin found")
+	}
+}
+
+func TestPutsWritesToOutput(t *testing.T) {
+	var out bytes.Buffer
+	result := GetBuiltinByName("puts").Fn(&out, &String{Value: "hello"}, &Integer{Value: 1}, &Array{Elements: []Object{TRUE}})
+	if result != NULL {
+		t.Errorf("puts did not return NULL. got=%T (%+v)", result, result)
+	}
+	if out.String() != "hello\n1\n[true]\n" {
+		t.Errorf("puts wrote wrong output. got=%q", out.String())
+	}
+}
+
+func TestPushDoesNotModifyArgument(t *testing.T) {
+	arr := &Array{Elements: []Object{&Integer{Value: 1}}}
+	result := GetBuiltinByName("push").Fn(nil, arr, &Integer{Value: 2})
+	if arr.Inspect() != "[1]" {
+		t.Errorf("push modified its argument. got=%s", arr.Inspect())
+	}
+	if result.Inspect() != "[1, 2]" {
+		t.Errorf("push returned wrong array. got=%s", result.Inspect())
+	}
+}
@@ -0,0 +1,90 @@
+package object
+
+import (
+	"io"
+	"os"
+)
+
+/*
+Environment
+識別子と値の対応を保持する環境
+*/
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	out   io.Writer
+}
+
+func NewEnvironment() *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil}
+}
+
+/*
+NewEnclosedEnvironment
+outerを外側の環境として持つ新しい環境を生成する
+*/
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+/*
+Get
+識別子に束縛された値を取得する
+見つからなければ外側の環境を辿る
+*/
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+/*
+Output
+組み込み関数が出力に使うWriterを返す
+設定されていなければ外側の環境を辿り、どこにもなければ標準出力を返す
+*/
+func (e *Environment) Output() io.Writer {
+	for env := e; env != nil; env = env.outer {
+		if env.out != nil {
+			return env.out
+		}
+	}
+	return os.Stdout
+}
+
+/*
+SetOutput
+組み込み関数の出力先を設定する
+*/
+func (e *Environment) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+/*
+Set
+識別子に値を束縛する
+*/
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+/*
+Assign
+すでに束縛されている識別子の値を置き換える
+束縛は見つかった環境で書き換え、どこにも束縛がなければfalseを返す
+*/
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
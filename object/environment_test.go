@@ -0,0 +1,76 @@
+package object
+
+import "testing"
+
+func TestEnvironmentGetSet(t *testing.T) {
+	env := NewEnvironment()
+	if _, ok := env.Get("x"); ok {
+		t.Fatalf("empty environment has binding for x")
+	}
+	env.Set("x", &Integer{Value: 1})
+	obj, ok := env.Get("x")
+	if !ok {
+		t.Fatalf("binding for x not found")
+	}
+	if obj.Inspect() != "1" {
+		t.Errorf("x has wrong value. got=%s", obj.Inspect())
+	}
+}
+
+func TestEnclosedEnvironment(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("y", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("x", &Integer{Value: 10})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected string
+	}{
+		{inner, "x", "10"},
+		{inner, "y", "2"},
+		{outer, "x", "1"},
+	}
+	for i, tt := range tests {
+		obj, ok := tt.env.Get(tt.name)
+		if !ok {
+			t.Errorf("tests[%d] - binding for %s not found", i, tt.name)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s has wrong value. expected=%s, got=%s", i, tt.name, tt.expected, obj.Inspect())
+		}
+	}
+	inner.Set("z", &Integer{Value: 3})
+	if _, ok := outer.Get("z"); ok {
+		t.Errorf("binding in inner environment leaked to outer")
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("y", &Integer{Value: 2})
+
+	if !inner.Assign("x", &Integer{Value: 10}) {
+		t.Fatalf("assigning x through inner environment failed")
+	}
+	if obj, _ := outer.Get("x"); obj.Inspect() != "10" {
+		t.Errorf("x in outer environment not updated. got=%s", obj.Inspect())
+	}
+	if !inner.Assign("y", &Integer{Value: 20}) {
+		t.Fatalf("assigning y failed")
+	}
+	if _, ok := outer.Get("y"); ok {
+		t.Errorf("assigning y leaked to outer environment")
+	}
+	if inner.Assign("z", &Integer{Value: 3}) {
+		t.Errorf("assigning undeclared z succeeded")
+	}
+	if _, ok := inner.Get("z"); ok {
+		t.Errorf("failed assignment created a binding for z")
+	}
+}
@@ -0,0 +1,96 @@
+package object
+
+import "sort"
+
+/*
+Iterator
+for-in文で繰り返しの対象から値を一つずつ取り出す型
+*/
+type Iterator struct {
+	next func() (Object, bool)
+}
+
+func (it *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+/*
+Next
+次の値を返す
+値が残っていなければfalseを返す
+*/
+func (it *Iterator) Next() (Object, bool) {
+	return it.next()
+}
+
+/*
+NewIterator
+繰り返しの対象からIteratorを生成する
+
+  - 整数nは0からn-1までの整数を順に返す
+  - 配列は生成した時点の長さの分だけ要素を先頭から順に返す
+  - ハッシュは生成した時点のキーを並べ替えて返す
+
+繰り返せない値であればfalseを返す
+*/
+func NewIterator(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		n := obj.Value
+		var i int64
+		return &Iterator{next: func() (Object, bool) {
+			if i >= n {
+				return nil, false
+			}
+			i++
+			return &Integer{Value: i - 1}, true
+		}}, true
+	case *Array:
+		return sliceIterator(obj.Elements), true
+	case *Hash:
+		return sliceIterator(sortedKeys(obj)), true
+	}
+	return nil, false
+}
+
+func sliceIterator(elements []Object) *Iterator {
+	i := 0
+	return &Iterator{next: func() (Object, bool) {
+		if i >= len(elements) {
+			return nil, false
+		}
+		i++
+		return elements[i-1], true
+	}}
+}
+
+/*
+sortedKeys
+ハッシュのキーを型名の順に、同じ型の中では値の順に並べて返す
+*/
+func sortedKeys(h *Hash) []Object {
+	keys := make([]Object, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		keys = append(keys, pair.Key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Type() != b.Type() {
+			return a.Type() < b.Type()
+		}
+		switch a := a.(type) {
+		case *Integer:
+			return a.Value < b.(*Integer).Value
+		case *String:
+			return a.Value < b.(*String).Value
+		case *Boolean:
+			return !a.Value && b.(*Boolean).Value
+		}
+		return false
+	})
+	return keys
+}
@@ -0,0 +1,426 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"interpreter/ast"
+	"interpreter/code"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	STRING_OBJ       = "STRING"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BUILTIN_OBJ      = "BUILTIN"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+	CELL_OBJ              = "CELL"
+
+	BREAK_OBJ    = "BREAK"
+	CONTINUE_OBJ = "CONTINUE"
+	ITERATOR_OBJ = "ITERATOR"
+)
+
+/*
+Object
+評価結果の値を表すインターフェース
+*/
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+// Integer
+
+/*
+Integer
+整数値の型
+*/
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (i *Integer) Inspect() string {
+	return fmt.Sprintf("%d", i.Value)
+}
+
+// Float
+
+/*
+Float
+浮動小数点数の型
+*/
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+/*
+Inspect
+値を読み戻せる最も短い形で返す
+整数と区別できるよう、小数点も指数もなければ".0"を付ける
+*/
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// String
+
+/*
+String
+文字列の型
+*/
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+
+// Boolean
+
+/*
+Boolean
+真偽値の型
+*/
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType {
+	return BOOLEAN_OBJ
+}
+
+func (b *Boolean) Inspect() string {
+	return fmt.Sprintf("%t", b.Value)
+}
+
+// Null
+
+/*
+Null
+値が存在しないことを表す型
+*/
+type Null struct{}
+
+func (n *Null) Type() ObjectType {
+	return NULL_OBJ
+}
+
+func (n *Null) Inspect() string {
+	return "null"
+}
+
+/*
+NULL, TRUE, FALSE
+値が一意であるオブジェクトのシングルトン
+比較はポインタの同一性で行えるため、評価器はこれらを使い回す
+*/
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+/*
+NativeBoolToBooleanObject
+Goの真偽値をシングルトンのBooleanに変換する
+*/
+func NativeBoolToBooleanObject(input bool) *Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+// ReturnValue
+
+/*
+ReturnValue
+return文で返された値を包む型
+*/
+type ReturnValue struct {
+	Value Object
+}
+
+func (r *ReturnValue) Type() ObjectType {
+	return RETURN_VALUE_OBJ
+}
+
+func (r *ReturnValue) Inspect() string {
+	return r.Value.Inspect()
+}
+
+// Break, Continue
+
+/*
+Break
+break文を評価した値
+評価器は囲んでいるループまでこの値を伝播させる
+*/
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+/*
+Continue
+continue文を評価した値
+*/
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// Error
+
+/*
+Error
+実行時エラーの型
+*/
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType {
+	return ERROR_OBJ
+}
+
+func (e *Error) Inspect() string {
+	return "ERROR: " + e.Message
+}
+
+// Function
+
+/*
+Function
+関数リテラルを評価した値の型
+*/
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	var params []string
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
+// Array
+
+/*
+Array
+配列の型
+*/
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// Hash
+
+/*
+HashKey
+ハッシュのキーとして使う値
+型が違えば値が同じでも別のキーになる
+*/
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+/*
+Hashable
+ハッシュのキーとして使えるオブジェクト
+*/
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+/*
+HashPair
+ハッシュに格納する元のキーと値の組
+*/
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+/*
+Hash
+ハッシュの型
+*/
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+/*
+Inspect
+出力が毎回同じになるようにキーの表示順に並べる
+*/
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	var pairs []string
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	sort.Strings(pairs)
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// CompiledFunction
+
+/*
+CompiledFunction
+コンパイル済みの関数の型
+NumLocalsは引数を含むローカル変数の数
+LocalNamesとFreeNamesは値のない変数を読んだときのエラーに使う名前で、番号で引く
+*/
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string
+	FreeNames     []string
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure
+
+/*
+Closure
+コンパイル済みの関数と、それが捕捉した自由変数の組
+*/
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Type() ObjectType {
+	return CLOSURE_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell
+
+/*
+Cell
+クロージャに捕捉された変数の値を入れる箱
+捕捉した側と捕捉された側が同じCellを参照し、代入を共有する
+*/
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+func (c *Cell) Inspect() string {
+	return c.Value.Inspect()
+}
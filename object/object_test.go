@@ -0,0 +1,175 @@
+package object
+
+import (
+	"interpreter/ast"
+	"interpreter/token"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestObjectType(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected ObjectType
+	}{
+		{&Integer{Value: 1}, INTEGER_OBJ},
+		{&Float{Value: 1}, FLOAT_OBJ},
+		{&String{Value: "a"}, STRING_OBJ},
+		{TRUE, BOOLEAN_OBJ},
+		{NULL, NULL_OBJ},
+		{&ReturnValue{Value: &Integer{Value: 1}}, RETURN_VALUE_OBJ},
+		{&Error{Message: "boom"}, ERROR_OBJ},
+		{&Function{}, FUNCTION_OBJ},
+		{&Array{}, ARRAY_OBJ},
+		{&Hash{}, HASH_OBJ},
+	}
+	for i, tt := range tests {
+		if tt.obj.Type() != tt.expected {
+			t.Errorf("tests[%d] - wrong type. expected=%q, got=%q", i, tt.expected, tt.obj.Type())
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	body := &ast.BlockStatement{
+		Token: token.Token{Type: token.LBRACE, Literal: "{"},
+		Statements: []ast.Statement{
+			&ast.ExpressionStatement{
+				Token: token.Token{Type: token.IDENT, Literal: "x"},
+				Expression: &ast.Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "x"},
+					Value: "x",
+				},
+			},
+		},
+	}
+	param := &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&Integer{Value: -42}, "-42"},
+		{&Float{Value: 3}, "3.0"},
+		{&Float{Value: -0.5}, "-0.5"},
+		{&Float{Value: 0.30000000000000004}, "0.30000000000000004"},
+		{&Float{Value: 1e21}, "1e+21"},
+		{&Float{Value: 1e-9}, "1e-09"},
+		{&Float{Value: math.Inf(1)}, "+Inf"},
+		{&Float{Value: math.NaN()}, "NaN"},
+		{&String{Value: "hello"}, "hello"},
+		{TRUE, "true"},
+		{FALSE, "false"},
+		{NULL, "null"},
+		{&ReturnValue{Value: &Integer{Value: 7}}, "7"},
+		{&Error{Message: "boom"}, "ERROR: boom"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Array{}, TRUE}}, "[1, [], true]"},
+		{&Function{Parameters: []*ast.Identifier{param}, Body: body}, "fn(x) {\nx\n}"},
+	}
+	for i, tt := range tests {
+		if tt.obj.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - wrong inspect. expected=%q, got=%q", i, tt.expected, tt.obj.Inspect())
+		}
+	}
+}
+
+func TestNativeBoolToBooleanObject(t *testing.T) {
+	if NativeBoolToBooleanObject(true) != TRUE {
+		t.Errorf("NativeBoolToBooleanObject(true) is not TRUE")
+	}
+	if NativeBoolToBooleanObject(false) != FALSE {
+		t.Errorf("NativeBoolToBooleanObject(false) is not FALSE")
+	}
+}
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyDistinguishesTypes(t *testing.T) {
+	if (&Integer{Value: 1}).HashKey() == TRUE.HashKey() {
+		t.Errorf("integer 1 and true have same hash keys")
+	}
+	if (&Integer{Value: 0}).HashKey() == FALSE.HashKey() {
+		t.Errorf("integer 0 and false have same hash keys")
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	one := &String{Value: "one"}
+	two := &Integer{Value: 2}
+	h := &Hash{Pairs: map[HashKey]HashPair{
+		one.HashKey(): {Key: one, Value: &Integer{Value: 1}},
+		two.HashKey(): {Key: two, Value: TRUE},
+	}}
+	if h.Inspect() != "{2: true, one: 1}" {
+		t.Errorf("h.Inspect() wrong. got=%q", h.Inspect())
+	}
+}
+
+func TestIterator(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{&String{Value: "b"}, &Integer{Value: 10}, &String{Value: "a"}, &Integer{Value: 9}, TRUE, FALSE} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: NULL}
+	}
+	tests := []struct {
+		iterable Object
+		expected []string
+	}{
+		{&Integer{Value: 3}, []string{"0", "1", "2"}},
+		{&Integer{Value: 0}, nil},
+		{&Array{Elements: []Object{&String{Value: "x"}, TRUE}}, []string{"x", "true"}},
+		{hash, []string{"false", "true", "9", "10", "a", "b"}},
+	}
+	for _, tt := range tests {
+		it, ok := NewIterator(tt.iterable)
+		if !ok {
+			t.Errorf("%s: not iterable", tt.iterable.Inspect())
+			continue
+		}
+		var got []string
+		for {
+			value, ok := it.Next()
+			if !ok {
+				break
+			}
+			got = append(got, value.Inspect())
+		}
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: wrong values. expected=%v, got=%v", tt.iterable.Inspect(), tt.expected, got)
+		}
+	}
+	if _, ok := NewIterator(&String{Value: "abc"}); ok {
+		t.Errorf("string should not be iterable")
+	}
+}
+
+func TestIntPow(t *testing.T) {
+	tests := []struct {
+		base, exp, expected int64
+	}{
+		{2, 0, 1},
+		{2, 10, 1024},
+		{-3, 3, -27},
+		{0, 0, 1},
+		{10, 18, 1000000000000000000},
+		{2, 64, 0},
+	}
+	for _, tt := range tests {
+		if got := IntPow(tt.base, tt.exp); got != tt.expected {
+			t.Errorf("IntPow(%d, %d) wrong. expected=%d, got=%d", tt.base, tt.exp, tt.expected, got)
+		}
+	}
+}
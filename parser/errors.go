@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"interpreter/token"
+	"sort"
+)
+
+/*
+ErrorKind
+構文エラーの種類
+*/
+type ErrorKind int
+
+const (
+	// UnexpectedToken 次のトークンが期待したものではなかった
+	UnexpectedToken ErrorKind = iota
+	// NoPrefixParseFn 式の先頭に置けないトークンが現れた
+	NoPrefixParseFn
+	// InvalidInteger 整数リテラルを数値に変換できなかった
+	InvalidInteger
+	// IllegalToken 字句解析器が不正なトークンを返した
+	IllegalToken
+	// OutsideLoop break文かcontinue文がループの外に現れた
+	OutsideLoop
+	// ShadowAfterUse 関数の中で外側の変数を読んだ後に、同じ名前の変数を定義した
+	ShadowAfterUse
+	// InvalidAssignTarget 代入できない式に代入しようとした
+	InvalidAssignTarget
+	// InvalidFloat 浮動小数点数リテラルを数値に変換できなかった
+	InvalidFloat
+)
+
+var errorKindNames = map[ErrorKind]string{
+	UnexpectedToken:     "UnexpectedToken",
+	NoPrefixParseFn:     "NoPrefixParseFn",
+	InvalidInteger:      "InvalidInteger",
+	IllegalToken:        "IllegalToken",
+	OutsideLoop:         "OutsideLoop",
+	ShadowAfterUse:      "ShadowAfterUse",
+	InvalidAssignTarget: "InvalidAssignTarget",
+	InvalidFloat:        "InvalidFloat",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+/*
+ParseError
+構文エラーの型
+Expectedは期待していたトークンの種類、Actualは実際に現れたトークン
+*/
+type ParseError struct {
+	Kind     ErrorKind
+	Expected []token.TokenType
+	Actual   token.Token
+	Pos      token.Position
+	Message  string
+}
+
+/*
+Error
+"line:col: message"の形式でエラーを返す
+*/
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+/*
+ErrorList
+構文エラーのリスト
+*/
+type ErrorList []*ParseError
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l ErrorList) Less(i, j int) bool {
+	a, b := l[i].Pos, l[j].Pos
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	if a.Column != b.Column {
+		return a.Column < b.Column
+	}
+	return l[i].Message < l[j].Message
+}
+
+/*
+Sort
+位置の順にエラーを並べ替える
+*/
+func (l ErrorList) Sort() {
+	sort.Sort(l)
+}
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+/*
+Err
+エラーがなければnilを、あればリスト自身をerrorとして返す
+*/
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+/*
+Strings
+REPLなどで表示するための文字列のリストを返す
+*/
+func (l ErrorList) Strings() []string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return msgs
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"interpreter/lexer"
+	"interpreter/token"
+	"testing"
+)
+
+func TestParseErrorKinds(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedKind     ErrorKind
+		expectedExpected []token.TokenType
+		expectedActual   token.TokenType
+	}{
+		{"let x 5;", UnexpectedToken, []token.TokenType{token.ASSIGN}, token.INT},
+		{"if x", UnexpectedToken, []token.TokenType{token.LPAREN}, token.IDENT},
+		{";", NoPrefixParseFn, nil, token.SEMICOLON},
+		{"99999999999999999999", InvalidInteger, nil, token.INT},
+		{"1e400", InvalidFloat, nil, token.FLOAT},
+		{"1 + /* open", IllegalToken, nil, token.ILLEGAL},
+		{"let @ = 1;", IllegalToken, nil, token.ILLEGAL},
+		{"break;", OutsideLoop, nil, token.BREAK},
+		{"1 = 2", InvalidAssignTarget, nil, token.ASSIGN},
+		{"fn() { x; let x = 1; }", ShadowAfterUse, nil, token.IDENT},
+		{"for x in y", UnexpectedToken, []token.TokenType{token.LPAREN}, token.IDENT},
+		{"for (x of y)", UnexpectedToken, []token.TokenType{token.IN}, token.IDENT},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q - expected parser errors", tt.input)
+			continue
+		}
+		err := errors[0]
+		if err.Kind != tt.expectedKind {
+			t.Errorf("%q - wrong kind. expected=%s, got=%s", tt.input, tt.expectedKind, err.Kind)
+		}
+		if len(err.Expected) != len(tt.expectedExpected) {
+			t.Errorf("%q - wrong expected tokens. expected=%v, got=%v", tt.input, tt.expectedExpected, err.Expected)
+		} else {
+			for i, e := range tt.expectedExpected {
+				if err.Expected[i] != e {
+					t.Errorf("%q - wrong expected tokens. expected=%v, got=%v", tt.input, tt.expectedExpected, err.Expected)
+				}
+			}
+		}
+		if err.Actual.Type != tt.expectedActual {
+			t.Errorf("%q - wrong actual token. expected=%s, got=%s", tt.input, tt.expectedActual, err.Actual.Type)
+		}
+		if err.Pos != err.Actual.Pos {
+			t.Errorf("%q - error position %s differs from actual token position %s", tt.input, err.Pos, err.Actual.Pos)
+		}
+	}
+}
+
+func TestErrorListSortAndError(t *testing.T) {
+	list := ErrorList{
+		{Pos: token.Position{Line: 3, Column: 1}, Message: "c"},
+		{Pos: token.Position{Line: 1, Column: 5}, Message: "b"},
+		{Pos: token.Position{Line: 1, Column: 2}, Message: "a"},
+	}
+	list.Sort()
+	expected := []string{"1:2: a", "1:5: b", "3:1: c"}
+	strs := list.Strings()
+	for i, e := range expected {
+		if strs[i] != e {
+			t.Errorf("list[%d] wrong. expected=%q, got=%q", i, e, strs[i])
+		}
+	}
+	if list.Error() != "1:2: a (and 2 more errors)" {
+		t.Errorf("list.Error() wrong. got=%q", list.Error())
+	}
+	if (ErrorList{}).Err() != nil {
+		t.Errorf("empty list Err() is not nil")
+	}
+	if list.Err() == nil {
+		t.Errorf("non-empty list Err() is nil")
+	}
+}
+
+func TestIllegalTokenMessages(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\n/* never closed", "2:1: unterminated comment"},
+		{`let s = "abc`, "1:9: unterminated string"},
+		{`"a\qb"`, `1:1: unknown escape sequence \q`},
+		{"let @ = 1;", "1:5: unexpected character '@'"},
+		{"let mask = 0x;", "1:12: hexadecimal literal has no digits"},
+		{"let n = 1__000;", "1:9: '_' must separate successive digits"},
+		{"0x1_0000_0000_0000_0000", "1:1: could not parse \"0x1_0000_0000_0000_0000\" as integer"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q - expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%q - wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
@@ -11,34 +11,70 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
+	POWER       // **
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 // 演算子の優先順位
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLUSH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLUSH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PIPE:            BIT_OR,
+	token.CARET:           BIT_XOR,
+	token.AMPERSAND:       BIT_AND,
+	token.SHL:             SHIFT,
+	token.SHR:             SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLUSH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 type Parser struct {
 	l              *lexer.Lexer
-	curToken       token.Token // current token
-	peekToken      token.Token // next token
-	errors         []string
+	prevToken      token.Token  // previous token
+	curToken       token.Token  // current token
+	peekToken      token.Token  // next token
+	pending        *token.Token // token pushed back by backup
+	errors         ErrorList
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	recovering  bool           // エラーを記録してから同期するまでの間true
+	blockDepth  int            // 解析中のブロックの深さ
+	closedBrace token.Position // 最後にブロックかハッシュを閉じた'}'の位置
+	atBlockEnd  bool           // 同期が対応する'{'のない'}'で止まった
+	loopDepth   int            // 解析中のループの深さ、関数リテラルの中では0から数え直す
+
+	comments []*ast.Comment // 読み飛ばしたコメント
 }
 
 type (
@@ -55,8 +91,40 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	if p.pending != nil {
+		p.peekToken = *p.pending
+		p.pending = nil
+	} else {
+		p.peekToken = p.readToken()
+	}
+}
+
+/*
+readToken
+字句解析器から次のトークンを読む
+COMMENTトークンは構文には含めず、Program.Commentsに集める
+*/
+func (p *Parser) readToken() token.Token {
+	tok := p.l.NextToken()
+	for tok.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: tok})
+		tok = p.l.NextToken()
+	}
+	return tok
+}
+
+/*
+backup
+トークンを一つ戻す
+直前にnextTokenを呼んだ後に一度だけ使える
+*/
+func (p *Parser) backup() {
+	peek := p.peekToken
+	p.pending = &peek
+	p.peekToken = p.curToken
+	p.curToken = p.prevToken
 }
 
 /**
@@ -121,12 +189,25 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	defer untrace(trace("parseExpression"))
+	start := p.curToken
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		if p.curTokenIs(token.ILLEGAL) {
+			p.illegalTokenError(p.curToken)
+		} else {
+			p.noPrefixParseFnError(p.curToken.Type)
+		}
+		if p.curTokenIs(token.RBRACE) && p.blockDepth > 0 {
+			// 囲んでいるブロックを閉じる'}'は式に含めず、ブロックの解析に残す
+			p.backup()
+			return &ast.BadExpression{Token: start, From: start.Pos, To: start.Pos}
+		}
+		return p.badExpression(start)
 	}
 	leftExp := prefix()
+	if leftExp == nil {
+		return p.badExpression(start)
+	}
 	// 中置演算子を探索
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -135,6 +216,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		}
 		p.nextToken()
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			return p.badExpression(start)
+		}
 	}
 	return leftExp
 }
@@ -156,14 +240,104 @@ parseStatement
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.FOR:
+		if stmt := p.parseForInStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.BREAK:
+		return &ast.BreakStatement{Token: p.parseLoopControl()}
+	case token.CONTINUE:
+		return &ast.ContinueStatement{Token: p.parseLoopControl()}
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+/*
+parseStatementWithRecovery
+文の構文解析を行い、エラーがあれば次の同期点まで読み飛ばす
+文として組み立てられなかった場合はBadStatementを返す
+*/
+func (p *Parser) parseStatementWithRecovery() ast.Statement {
+	start := p.curToken
+	wasRecovering := p.recovering
+	stmt := p.parseStatement()
+	if wasRecovering || !p.recovering {
+		return stmt
+	}
+	p.synchronize()
+	p.recovering = false
+	if stmt == nil {
+		return &ast.BadStatement{Token: start, From: start.Pos, To: p.curToken.End}
+	}
+	return stmt
+}
+
+// 文の先頭になるトークン
+var syncKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.IF:       true,
+	token.FUNCTION: true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
+/*
+synchronize
+エラーの後、文の区切りまでトークンを読み飛ばす
+';'か、次のトークンが文の先頭になるキーワードか'}'になったところで止まる
+*/
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			} else if p.blockDepth > 0 && p.curToken.Pos != p.closedBrace {
+				// 囲んでいるブロックの終わり
+				p.atBlockEnd = true
+				return
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+		if depth == 0 {
+			if syncKeywords[p.peekToken.Type] || p.blockDepth > 0 && p.peekTokenIs(token.RBRACE) {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+/*
+badExpression
+startから現在のトークンまでを覆うBadExpressionを生成する
+*/
+func (p *Parser) badExpression(start token.Token) ast.Expression {
+	return &ast.BadExpression{Token: start, From: start.Pos, To: p.curToken.End}
+}
+
 /*
 ParseProgram
 ASTのルートノードを生成
@@ -172,12 +346,14 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementWithRecovery()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
+	p.checkScopes(program)
 	return program
 }
 
@@ -198,14 +374,46 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&ParseError{
+			Kind:    InvalidInteger,
+			Actual:  p.curToken,
+			Pos:     p.curToken.Pos,
+			Message: fmt.Sprintf("could not parse %q as integer", p.curToken.Literal),
+		})
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+/*
+parseFloatLiteral
+浮動小数点数リテラルの構文解析
+*/
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(&ParseError{
+			Kind:    InvalidFloat,
+			Actual:  p.curToken,
+			Pos:     p.curToken.Pos,
+			Message: fmt.Sprintf("could not parse %q as float", p.curToken.Literal),
+		})
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+/*
+parseStringLiteral
+文字列リテラルの構文解析
+*/
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 /*
 parsePrefixExpression
 前置演算子式の構文解析
@@ -226,8 +434,32 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 noPrefixParseFnError
 */
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(&ParseError{
+		Kind:    NoPrefixParseFn,
+		Actual:  p.curToken,
+		Pos:     p.curToken.Pos,
+		Message: fmt.Sprintf("no prefix parse function for %s found", t),
+	})
+}
+
+/*
+illegalTokenError
+字句解析器がILLEGALトークンを返した理由をエラーとして記録する
+*/
+func (p *Parser) illegalTokenError(tok token.Token) {
+	msg := fmt.Sprintf("illegal token %q", tok.Literal)
+	for _, err := range p.l.Errors() {
+		if tok.Pos.Offset <= err.Pos.Offset && err.Pos.Offset < tok.End.Offset {
+			msg = err.Msg
+			break
+		}
+	}
+	p.addError(&ParseError{
+		Kind:    IllegalToken,
+		Actual:  tok,
+		Pos:     tok.Pos,
+		Message: msg,
+	})
 }
 
 /*
@@ -242,17 +474,71 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 	precedence := p.curPrecedence()
+	if IsRightAssoc(p.curToken.Type) {
+		// 右辺に同じ強さの演算子を含めることで右結合にする
+		precedence--
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	return expression
+}
+
+/*
+IsRightAssoc
+右結合の中置演算子かを返す
+*/
+func IsRightAssoc(t token.TokenType) bool {
+	return t == token.POWER
+}
+
+/*
+parseLogicalExpression
+論理演算子の式の構文解析
+InfixExpressionと同じく左結合になる
+*/
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+	precedence := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 	return expression
 }
 
+/*
+parseAssignExpression
+代入式の構文解析
+右結合にするため、右辺は代入と同じ強さの演算子まで含めて解析する
+*/
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(&ParseError{
+			Kind:    InvalidAssignTarget,
+			Actual:  p.curToken,
+			Pos:     p.curToken.Pos,
+			Message: fmt.Sprintf("cannot assign to %s", target.String()),
+		})
+		return nil
+	}
+	p.nextToken()
+	exp.Value = p.parseExpression(ASSIGN - 1)
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	exp := &ast.GroupedExpression{Token: p.curToken}
 	p.nextToken()
-	exp := p.parseExpression(LOWEST)
+	exp.Expression = p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
+	exp.RParen = p.curToken
 	return exp
 }
 
@@ -283,20 +569,117 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+/*
+parseWhileStatement
+while文の構文解析
+*/
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+/*
+parseForInStatement
+for-in文の構文解析
+*/
+func (p *Parser) parseForInStatement() *ast.ForInStatement {
+	stmt := &ast.ForInStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+/*
+parseLoopBody
+ループの本体の構文解析
+本体の中ではbreak文とcontinue文が使える
+*/
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+/*
+parseLoopControl
+break文かcontinue文の構文解析を行い、そのトークンを返す
+ループの外にあればエラーを記録する
+*/
+func (p *Parser) parseLoopControl() token.Token {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		p.addError(&ParseError{
+			Kind:    OutsideLoop,
+			Actual:  tok,
+			Pos:     tok.Pos,
+			Message: fmt.Sprintf("%s outside loop", tok.Literal),
+		})
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return tok
+}
+
 /*
 ブロック式の構文解析
 */
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementWithRecovery()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.atBlockEnd {
+			p.atBlockEnd = false
+			break
+		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.RBRACE) {
+		block.RBrace = p.curToken
+		p.closedBrace = p.curToken.Pos
+	}
 	return block
 }
 
@@ -338,31 +721,36 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
+	// 関数の本体から外側のループをbreakすることはできない
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 	return lit
 }
 
 /*
-parseCallExpression
-呼び出し式の引数の構文解析
+parseExpressionList
+endで閉じられるカンマ区切りの式のリストの構文解析
+endで閉じられていなければfalseを返す
 */
-func (p *Parser) parseCallArguments() []ast.Expression {
-	var args []ast.Expression
-	if p.peekTokenIs(token.RPAREN) {
+func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, bool) {
+	var list []ast.Expression
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list, true
 	}
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if !p.expectPeek(end) {
+		return nil, false
 	}
-	return args
+	return list, true
 }
 
 /*
@@ -371,10 +759,73 @@ parseCallExpression
 */
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	args, ok := p.parseExpressionList(token.RPAREN)
+	if !ok {
+		return nil
+	}
+	exp.Arguments = args
+	exp.RParen = p.curToken
+	return exp
+}
+
+/*
+parseArrayLiteral
+配列リテラルの構文解析
+*/
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	elements, ok := p.parseExpressionList(token.RBRACKET)
+	if !ok {
+		return nil
+	}
+	array.Elements = elements
+	array.RBracket = p.curToken
+	return array
+}
+
+/*
+parseIndexExpression
+添字式の構文解析
+*/
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.RBracket = p.curToken
 	return exp
 }
 
+/*
+parseHashLiteral
+ハッシュリテラルの構文解析
+式の位置に現れた'{'はブロックではなくハッシュリテラルの始まりとして扱う
+*/
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, &ast.HashPair{Key: key, Value: value})
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.RBrace = p.curToken
+	p.closedBrace = p.curToken.Pos
+	return hash
+}
+
 /*
 parseBoolean
 真偽値の解析
@@ -384,31 +835,59 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 // Errors /**
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() ErrorList {
 	return p.errors
 }
 
+/*
+addError
+構文エラーを記録する
+一つの誤りにつき一つだけ記録するため、同期するまでの間のエラーは捨てる
+*/
+func (p *Parser) addError(err *ParseError) {
+	if p.recovering {
+		// 同期するまでは連鎖して起きるエラーを記録しない
+		return
+	}
+	p.recovering = true
+	p.errors = append(p.errors, err)
+}
+
 /**
 peekError
 トークンのタイプが合わない場合にエラーをerrorsに追加する
 */
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	if p.peekTokenIs(token.ILLEGAL) {
+		p.illegalTokenError(p.peekToken)
+		return
+	}
+	p.addError(&ParseError{
+		Kind:     UnexpectedToken,
+		Expected: []token.TokenType{t},
+		Actual:   p.peekToken,
+		Pos:      p.peekToken.Pos,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type),
+	})
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: ErrorList{}}
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -419,7 +898,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLUSH_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.nextToken()
 	p.nextToken()
 	return p
@@ -446,3 +942,15 @@ func (p *Parser) curPrecedence() int {
 	}
 	return LOWEST
 }
+
+/*
+Precedence
+中置演算子として使われるトークンの優先順位を返す
+中置演算子でなければLOWESTを返す
+*/
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
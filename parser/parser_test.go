@@ -179,6 +179,65 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{".5", 0.5},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestPrefixedIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0o755", 493},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_dead_BEEF", 0xdeadbeef},
+		{"0x7FFF_FFFF_FFFF_FFFF", 9223372036854775807},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %d. got=%d", tt.expected, literal.Value)
+		}
+		// 元の書き方のまま文字列に戻す
+		if program.String() != tt.input {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.input, program.String())
+		}
+	}
+}
+
 func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	integer, ok := il.(*ast.IntegerLiteral)
 	if !ok {
@@ -343,6 +402,33 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"f(x)[0]", "(f(x)[0])"},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))"},
+		{"a[i + 1] += b * c", "((a[(i + 1)]) += (b * c))"},
+		{"x = a == b", "(x = (a == b))"},
+		{"f(x = 1)", "f((x = 1))"},
+		{"x -= y *= 2", "(x -= (y *= 2))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a == b && c < d", "((a == b) && (c < d))"},
+		{"!a || -b", "((!a) || (-b))"},
+		{"x = a || b", "(x = (a || b))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a * b % c", "((a * b) % c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b << c + d", "(a & (b << (c + d)))"},
+		{"a >> b >> c", "((a >> b) >> c)"},
+		{"a & b == c | d", "((a & b) == (c | d))"},
+		{"~a & b", "((~a) & b)"},
+		{"-1.5 * .5 + 2e3", "(((-1.5) * .5) + 2e3)"},
+		{"a < b && c | d", "((a < b) && (c | d))"},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -450,3 +536,459 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestGroupedExpressionParsing(t *testing.T) {
+	input := "((a + b)) * c"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.InfixExpression. got=%T", stmt.Expression)
+	}
+	outer, ok := exp.Left.(*ast.GroupedExpression)
+	if !ok {
+		t.Fatalf("exp.Left not *ast.GroupedExpression. got=%T", exp.Left)
+	}
+	if _, ok := outer.Expression.(*ast.GroupedExpression); !ok {
+		t.Fatalf("outer.Expression not *ast.GroupedExpression. got=%T", outer.Expression)
+	}
+	if !testInfixExpression(t, ast.Unparen(exp.Left), "a", "+", "b") {
+		return
+	}
+	if outer.Pos().String() != "1:1" || outer.End().String() != "1:10" {
+		t.Errorf("grouped expression range wrong. got=%s-%s", outer.Pos(), outer.End())
+	}
+	if program.String() != "((a + b) * c)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		expectedEnd string
+	}{
+		{"let x = 5;", "1:1", "1:10"},
+		{"return a + b;", "1:1", "1:13"},
+		{"  a * (b + c)", "1:3", "1:14"},
+		{"-x", "1:1", "1:3"},
+		{"add(1, 2)", "1:1", "1:10"},
+		{"if (x) {\n  y\n} else {\n  z\n}", "1:1", "5:2"},
+		{"fn(a) {\n  a\n}", "1:1", "3:2"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0]
+		if stmt.Pos().String() != tt.expectedPos {
+			t.Errorf("%q - pos wrong. expected=%s, got=%s", tt.input, tt.expectedPos, stmt.Pos())
+		}
+		if stmt.End().String() != tt.expectedEnd {
+			t.Errorf("%q - end wrong. expected=%s, got=%s", tt.input, tt.expectedEnd, stmt.End())
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "1:7: expected next token to be =, got INT instead"},
+		{"let x = 1;\nlet = 10;", "2:5: expected next token to be IDENT, got = instead"},
+		{"\n  ;", "2:3: no prefix parse function for ; found"},
+		{"99999999999999999999", "1:1: could not parse \"99999999999999999999\" as integer"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q - expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%q - wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld";`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello\tworld" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld", literal.Value)
+	}
+	if literal.String() != `"hello\tworld"` {
+		t.Errorf("literal.String() not %q. got=%q", `"hello\tworld"`, literal.String())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+	if array.End().String() != "1:18" {
+		t.Errorf("array.End() wrong. got=%s", array.End())
+	}
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	l := lexer.New("[]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+	if indexExp.Pos().String() != "1:1" || indexExp.End().String() != "1:15" {
+		t.Errorf("index expression range wrong. got=%s-%s", indexExp.Pos(), indexExp.End())
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`{"one": 1, "two": 2, "three": 3}`, []string{`"one":1`, `"two":2`, `"three":3`}},
+		{`{}`, nil},
+		{`{1: true, true: "x", "k": 0 + 1,}`, []string{`1:true`, `true:"x"`, `"k":(0 + 1)`}},
+		{`{"a": {"b": [1]}}`, []string{`"a":{"b":[1]}`}},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+		}
+		if len(hash.Pairs) != len(tt.expected) {
+			t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+		}
+		for i, pair := range hash.Pairs {
+			actual := pair.Key.String() + ":" + pair.Value.String()
+			if actual != tt.expected[i] {
+				t.Errorf("hash.Pairs[%d] wrong. expected=%q, got=%q", i, tt.expected[i], actual)
+			}
+		}
+	}
+}
+
+func TestHashLiteralAndBlockDisambiguation(t *testing.T) {
+	input := `if (x) { {"a": 1} } else { fn() { {} } }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	inner := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := inner.Expression.(*ast.HashLiteral); !ok {
+		t.Errorf("consequence statement is not ast.HashLiteral. got=%T", inner.Expression)
+	}
+	fn := exp.Alternative.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := body.Expression.(*ast.HashLiteral); !ok {
+		t.Errorf("function body statement is not ast.HashLiteral. got=%T", body.Expression)
+	}
+}
+
+func TestParsingHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a" 1}`, "1:6: expected next token to be :, got INT instead"},
+		{`{"a": 1 "b": 2}`, "1:9: expected next token to be ,, got STRING instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q - expected 1 error. got=%q", tt.input, errors.Strings())
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%q - wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestParsingComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(a, b) { a + b /* sum */ };
+add(1, 2); // 3`
+	l := lexer.New(input)
+	l.SetMode(lexer.ScanComments)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	expected := []string{"// add two numbers", "/* sum */", "// 3"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("wrong number of comments. got=%d", len(program.Comments))
+	}
+	for i, c := range program.Comments {
+		if c.Token.Literal != expected[i] {
+			t.Errorf("comments[%d] wrong. expected=%q, got=%q", i, expected[i], c.Token.Literal)
+		}
+	}
+	if program.Comments[1].Pos().String() != "2:28" {
+		t.Errorf("comment position wrong. got=%s", program.Comments[1].Pos())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x; break; }`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+	if stmt.String() != "while ((x < 10)) xbreak;" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { if (item) { continue } }; item`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("stmt.Iterable is not ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+	if stmt.End().Column != 48 {
+		t.Errorf("stmt.End() wrong. got=%s", stmt.End())
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"while (true) { break; continue; }", nil},
+		{"for (x in 3) { while (x) { break } continue }", nil},
+		{"break;", []string{"1:1: break outside loop"}},
+		{"if (x) { continue }", []string{"1:10: continue outside loop"}},
+		{"while (true) { fn() { break; } }", []string{"1:23: break outside loop"}},
+		{"while (true) { let f = fn() { 1 }; break; }; break", []string{"1:46: break outside loop"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors().Strings()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong errors. expected=%v, got=%v", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, e := range tt.expected {
+			if errors[i] != e {
+				t.Errorf("%q: errors[%d] wrong. expected=%q, got=%q", tt.input, i, e, errors[i])
+			}
+		}
+	}
+}
+
+func TestShadowAfterUse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; let x = x + 1;", nil},
+		{"fn(x) { let y = x; let x = 2; }", nil},
+		{"fn() { let x = 1; let y = x; let x = 2; }", nil},
+		{"fn() { let f = fn() { f() }; }", nil},
+		{"fn() { let x = x + 1; }", []string{"1:12: cannot define x after using the outer x in the same function"}},
+		{"fn() { x = 1; let x = 2; }", []string{"1:19: cannot define x after using the outer x in the same function"}},
+		{"fn() { for (x in [x]) { x } }", []string{"1:13: cannot define x after using the outer x in the same function"}},
+		{"fn() { fn() { x }; let x = 1; }", []string{"1:24: cannot define x after using the outer x in the same function"}},
+		{"fn(x) { fn() { let y = x; let x = 1; } }", []string{"1:31: cannot define x after using the outer x in the same function"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors().Strings()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong errors. expected=%v, got=%v", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, e := range tt.expected {
+			if errors[i] != e {
+				t.Errorf("%q: errors[%d] wrong. expected=%q, got=%q", tt.input, i, e, errors[i])
+			}
+		}
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += y", "x", "+=", "y"},
+		{"x -= 1", "x", "-=", 1},
+		{"x *= true", "x", "*=", true},
+		{"x /= 2", "x", "/=", 2},
+		{`h["k"] = v`, `(h["k"])`, "=", "v"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target wrong. expected=%q, got=%q", tt.target, exp.Target.String())
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator wrong. expected=%q, got=%q", tt.operator, exp.Operator)
+		}
+		testLiteralExpression(t, exp.Value, tt.value)
+	}
+}
+
+func TestInvalidAssignTargets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"f() = 2", "1:5: cannot assign to f()"},
+		{"(x) = 2", "1:5: cannot assign to x"},
+		{"a + b = c", "1:7: cannot assign to (a + b)"},
+		{"let x = 1 += 2", "1:11: cannot assign to 1"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors().Strings()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong errors. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     interface{}
+		operator string
+		right    interface{}
+	}{
+		{"a && b", "a", "&&", "b"},
+		{"true || false", true, "||", false},
+		{"1 && 2;", 1, "&&", 2},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.LogicalExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.LogicalExpression. got=%T", stmt.Expression)
+		}
+		testLiteralExpression(t, exp.Left, tt.left)
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator wrong. expected=%q, got=%q", tt.operator, exp.Operator)
+		}
+		testLiteralExpression(t, exp.Right, tt.right)
+	}
+}
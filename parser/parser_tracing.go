@@ -7,6 +7,9 @@ import (
 
 var traceLevel int
 
+// traceEnabled trueのときだけ構文解析のトレースを出力する
+var traceEnabled bool
+
 const traceIdentPlaceholder string = "\t"
 
 func identLevel() string {
@@ -14,6 +17,9 @@ func identLevel() string {
 }
 
 func tracePrint(fs string) {
+	if !traceEnabled {
+		return
+	}
 	fmt.Printf("%s%s\n", identLevel(), fs)
 }
 
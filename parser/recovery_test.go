@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"interpreter/ast"
+	"interpreter/lexer"
+	"testing"
+)
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			"let x 5; let = 10; let y = 3;",
+			[]string{
+				"1:7: expected next token to be =, got INT instead",
+				"1:14: expected next token to be IDENT, got = instead",
+			},
+			[]string{"<bad statement>", "<bad statement>", "let y = 3;"},
+		},
+		{
+			"let a = ; a + 1;",
+			[]string{"1:9: no prefix parse function for ; found"},
+			[]string{"let a = <bad expression>;", "(a + 1)"},
+		},
+		{
+			"if x { y } let b = 2;",
+			[]string{"1:4: expected next token to be (, got IDENT instead"},
+			[]string{"<bad expression>", "let b = 2;"},
+		},
+		{
+			"let f = fn(x) { let = 1; x }; f(2);",
+			[]string{"1:21: expected next token to be IDENT, got = instead"},
+			[]string{"let f = fn(x) <bad statement>x;", "f(2)"},
+		},
+		{
+			"let g = fn() { let y = }; g();",
+			[]string{"1:24: no prefix parse function for } found"},
+			[]string{"let g = fn() let y = <bad expression>;;", "g()"},
+		},
+		{
+			"let h = fn() { let z }; h();",
+			[]string{"1:22: expected next token to be =, got } instead"},
+			[]string{"let h = fn() <bad statement>;", "h()"},
+		},
+		{
+			"let w = [1, 2; let z = 3;",
+			[]string{"1:14: expected next token to be ], got ; instead"},
+			[]string{"let w = <bad expression>;", "let z = 3;"},
+		},
+		{
+			"f(1, 2; puts(1);",
+			[]string{"1:7: expected next token to be ), got ; instead"},
+			[]string{"<bad expression>", "puts(1)"},
+		},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		errors := p.Errors().Strings()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q - wrong number of errors. expected=%q, got=%q", tt.input, tt.expectedErrors, errors)
+		} else {
+			for i, e := range tt.expectedErrors {
+				if errors[i] != e {
+					t.Errorf("%q - errors[%d] wrong. expected=%q, got=%q", tt.input, i, e, errors[i])
+				}
+			}
+		}
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Errorf("%q - wrong number of statements. expected=%d, got=%d (%q)", tt.input, len(tt.expectedStatements), len(program.Statements), program.String())
+			continue
+		}
+		for i, e := range tt.expectedStatements {
+			if program.Statements[i].String() != e {
+				t.Errorf("%q - statements[%d] wrong. expected=%q, got=%q", tt.input, i, e, program.Statements[i].String())
+			}
+		}
+	}
+}
+
+func TestBadNodePositions(t *testing.T) {
+	l := lexer.New("let x 5;\nlet y = 1;")
+	p := New(l)
+	program := p.ParseProgram()
+	bad, ok := program.Statements[0].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.BadStatement. got=%T", program.Statements[0])
+	}
+	if bad.Pos().String() != "1:1" || bad.End().String() != "1:9" {
+		t.Errorf("bad statement range wrong. got=%s-%s", bad.Pos(), bad.End())
+	}
+	if _, ok := program.Statements[1].(*ast.LetStatement); !ok {
+		t.Errorf("program.Statements[1] is not ast.LetStatement. got=%T", program.Statements[1])
+	}
+}
+
+func TestUnclosedListPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		from, to string
+	}{
+		{"let w = [1, 2;", "1:9", "1:14"},
+		{"f(1, 2;", "1:1", "1:7"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		var exp ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.LetStatement:
+			exp = stmt.Value
+		case *ast.ExpressionStatement:
+			exp = stmt.Expression
+		}
+		bad, ok := exp.(*ast.BadExpression)
+		if !ok {
+			t.Errorf("%q - expression is not ast.BadExpression. got=%T", tt.input, exp)
+			continue
+		}
+		if bad.Pos().String() != tt.from || bad.End().String() != tt.to {
+			t.Errorf("%q - bad expression range wrong. expected=%s-%s, got=%s-%s", tt.input, tt.from, tt.to, bad.Pos(), bad.End())
+		}
+	}
+}
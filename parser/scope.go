@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"interpreter/ast"
+)
+
+/*
+funcScope
+関数ごとに、定義した名前と外側の変数として読んだ名前を記録する
+*/
+type funcScope struct {
+	defined map[string]bool
+	used    map[string]bool
+}
+
+/*
+scopeChecker
+関数の中で外側の変数を読んだ後に、同じ名前をlet文やfor-in文で定義していないかを調べる
+
+評価器は実行時に環境をたどるので、定義の後はループの次の周回や先に作ったクロージャからも新しい変数が見える
+コンパイラは読んだ位置で名前を解決するので、外側の変数を読み続ける
+二つの実行方式で結果が変わらないように、このような定義を構文エラーにする
+トップレベルの変数は両方とも同じ場所を書き換えるので調べない
+*/
+type scopeChecker struct {
+	p      *Parser
+	scopes []*funcScope
+}
+
+/*
+checkScopes
+構文木全体を調べ、見つけた誤りをエラーとして記録する
+*/
+func (p *Parser) checkScopes(program *ast.Program) {
+	c := &scopeChecker{p: p}
+	c.check(program)
+}
+
+func (c *scopeChecker) check(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Identifier:
+		c.use(n.Value)
+	case *ast.LetStatement:
+		// コンパイラと同じく、関数リテラルなら名前を先に定義して本体から自分自身を参照できるようにする
+		if fl, ok := ast.Unparen(n.Value).(*ast.FunctionLiteral); ok {
+			c.define(n.Name)
+			c.check(fl)
+			return
+		}
+		if n.Value != nil {
+			c.check(n.Value)
+		}
+		c.define(n.Name)
+	case *ast.ForInStatement:
+		if n.Iterable != nil {
+			c.check(n.Iterable)
+		}
+		c.define(n.Variable)
+		if n.Body != nil {
+			c.check(n.Body)
+		}
+	case *ast.FunctionLiteral:
+		scope := &funcScope{defined: map[string]bool{}, used: map[string]bool{}}
+		for _, param := range n.Parameters {
+			if param != nil {
+				scope.defined[param.Value] = true
+			}
+		}
+		c.scopes = append(c.scopes, scope)
+		if n.Body != nil {
+			c.check(n.Body)
+		}
+		c.scopes = c.scopes[:len(c.scopes)-1]
+	default:
+		ast.Inspect(node, func(child ast.Node) bool {
+			if child == node {
+				return true
+			}
+			if child != nil {
+				c.check(child)
+			}
+			return false
+		})
+	}
+}
+
+/*
+use
+名前を読んだことを、その名前を定義した関数より内側のすべての関数に記録する
+*/
+func (c *scopeChecker) use(name string) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if c.scopes[i].defined[name] {
+			return
+		}
+		c.scopes[i].used[name] = true
+	}
+}
+
+/*
+define
+名前を一番内側の関数に定義する
+その関数の中で外側の変数として既に読んでいればエラーを記録する
+*/
+func (c *scopeChecker) define(ident *ast.Identifier) {
+	if ident == nil || len(c.scopes) == 0 {
+		return
+	}
+	scope := c.scopes[len(c.scopes)-1]
+	if scope.used[ident.Value] && !scope.defined[ident.Value] {
+		c.p.errors = append(c.p.errors, &ParseError{
+			Kind:    ShadowAfterUse,
+			Actual:  ident.Token,
+			Pos:     ident.Pos(),
+			Message: fmt.Sprintf("cannot define %s after using the outer %s in the same function", ident.Value, ident.Value),
+		})
+	}
+	scope.defined[ident.Value] = true
+}
@@ -3,13 +3,24 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
 	"interpreter/lexer"
+	"interpreter/object"
 	"interpreter/parser"
+	"interpreter/vm"
 	"io"
 )
 
 const PROMPT = ">> "
 
+// 実行エンジン
+const (
+	EngineEval = "eval" // 木構造をたどる評価器
+	EngineVM   = "vm"   // バイトコードコンパイラと仮想マシン
+)
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
@@ -23,10 +34,25 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func Start(in io.Reader, out io.Writer) {
+/*
+Start
+入力を一行ずつengineで実行する
+状態は行をまたいで共有されるので、前の行で束縛した値を後の行で参照できる
+*/
+func Start(in io.Reader, out io.Writer, engine string) {
+	if engine == EngineVM {
+		startVM(in, out)
+		return
+	}
+	startEval(in, out)
+}
+
+func startEval(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
+	env.SetOutput(out)
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -37,14 +63,85 @@ func Start(in io.Reader, out io.Writer) {
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, p.Errors().Strings())
 			continue
 		}
-		io.WriteString(out, program.String())
+		evaluated := evaluator.Eval(program, env)
+		if evaluated == nil {
+			continue
+		}
+		if errObj, ok := evaluated.(*object.Error); ok {
+			printRuntimeError(out, errObj)
+			continue
+		}
+		io.WriteString(out, evaluated.Inspect())
 		io.WriteString(out, "\n")
 	}
 }
 
+/*
+startVM
+各行をコンパイルしてVMで実行する
+定数、シンボル表、グローバル変数は行をまたいで引き継ぐ
+*/
+func startVM(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, b := range object.Builtins {
+		symbolTable.DefineBuiltin(i, b.Name)
+	}
+	for {
+		fmt.Fprint(out, PROMPT)
+		scanned := scanner.Scan()
+		if !scanned {
+			return
+		}
+		line := scanner.Text()
+		l := lexer.New(line)
+		p := parser.New(l)
+
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParserErrors(out, p.Errors().Strings())
+			continue
+		}
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			printCompileError(out, err)
+			continue
+		}
+		code := comp.Bytecode()
+		constants = code.Constants
+		machine := vm.NewWithGlobals(code, globals)
+		machine.SetOutput(out)
+		if err := machine.Run(); err != nil {
+			printRuntimeError(out, &object.Error{Message: err.Error()})
+			continue
+		}
+		last := machine.LastPoppedStackElem()
+		if !endsWithExpression(program) || last == nil {
+			continue
+		}
+		io.WriteString(out, last.Inspect())
+		io.WriteString(out, "\n")
+	}
+}
+
+/*
+endsWithExpression
+最後の文が値を残す式文かどうかを返す
+*/
+func endsWithExpression(program *ast.Program) bool {
+	n := len(program.Statements)
+	if n == 0 {
+		return false
+	}
+	_, ok := program.Statements[n-1].(*ast.ExpressionStatement)
+	return ok
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops!We ran into some monkey business here!\n")
@@ -53,3 +150,13 @@ func printParserErrors(out io.Writer, errors []string) {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
+
+func printCompileError(out io.Writer, err error) {
+	io.WriteString(out, " compile error:\n")
+	io.WriteString(out, "\t"+err.Error()+"\n")
+}
+
+func printRuntimeError(out io.Writer, err *object.Error) {
+	io.WriteString(out, " runtime error:\n")
+	io.WriteString(out, "\t"+err.Message+"\n")
+}
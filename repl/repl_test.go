@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStartKeepsEnvironmentBetweenLines(t *testing.T) {
+	in := strings.NewReader("let x = 5;\nx * 2\n")
+	var out bytes.Buffer
+	Start(in, &out, EngineEval)
+	expected := PROMPT + PROMPT + "10\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartReportsErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		absent   string
+	}{
+		{"let = 5;\n", " parser errors:\n", " runtime error:\n"},
+		{"foobar\n", " runtime error:\n\tidentifier not found: foobar\n", " parser errors:\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out, EngineEval)
+		if !strings.Contains(out.String(), tt.expected) {
+			t.Errorf("output does not contain %q. got=%q", tt.expected, out.String())
+		}
+		if strings.Contains(out.String(), tt.absent) {
+			t.Errorf("output unexpectedly contains %q. got=%q", tt.absent, out.String())
+		}
+	}
+}
+
+func TestStartWritesPutsToOutput(t *testing.T) {
+	in := strings.NewReader(`puts("hi")` + "\n")
+	var out bytes.Buffer
+	Start(in, &out, EngineEval)
+	expected := PROMPT + "hi\nnull\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartWithVM(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;\nx * 2\n", PROMPT + PROMPT + "10\n" + PROMPT},
+		{"let add = fn(a, b) { a + b };\nadd(1, 2)\n", PROMPT + PROMPT + "3\n" + PROMPT},
+		{`puts("hi")` + "\n", PROMPT + "hi\nnull\n" + PROMPT},
+		{"foobar\n", PROMPT + " compile error:\n\tidentifier not found: foobar\n" + PROMPT},
+		{"1 / 0\n2\n", PROMPT + " runtime error:\n\tdivision by zero\n" + PROMPT + "2\n" + PROMPT},
+		{"let y = 1 / 0;\ny\n", PROMPT + " runtime error:\n\tdivision by zero\n" + PROMPT + " runtime error:\n\tidentifier not found: y\n" + PROMPT},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out, EngineVM)
+		if out.String() != tt.expected {
+			t.Errorf("%q - wrong output. expected=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
@@ -1,22 +1,72 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // トークンの開始位置
+	End     Position // トークンの直後の位置
+}
+
+/*
+Position
+ソースコード上の位置
+Line, Columnは1始まり、Offsetは0始まりのバイト位置
+Columnは行頭から数えた文字の数で、マルチバイト文字も一文字と数える
+*/
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+/*
+IsValid
+位置情報が設定されているかを返す
+*/
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+/*
+String
+"file:line:col"の形式で位置を返す
+ファイル名がなければ"line:col"になる
+*/
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	// COMMENT "// ..." or "/* ... */"
+	COMMENT = "COMMENT"
 
 	// identifier + literal
 
 	// IDENT add, foobar, x, y, ...
 	IDENT = "IDENT"
-	// INT 12345
+	// INT 12345, 0xFF, 0o755, 0b1010, 1_000
 	INT = "INT"
+	// FLOAT 3.14, 1e-9, .5
+	FLOAT = "FLOAT"
+	// STRING "foobar"
+	STRING = "STRING"
 
 	// operator
 
@@ -32,6 +82,31 @@ const (
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	LT_EQ    = "<="
+	GT_EQ    = ">="
+	PERCENT  = "%"
+	POWER    = "**"
+
+	// bitwise operator
+
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	TILDE     = "~"
+	SHL       = "<<"
+	SHR       = ">>"
+
+	// logical operator
+
+	AND = "&&"
+	OR  = "||"
+
+	// compound assignment
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLUSH_ASSIGN    = "/="
 
 	// delimiter
 
@@ -39,6 +114,8 @@ const (
 	COMMA = ","
 	// SEMICOLON semicolon
 	SEMICOLON = ";"
+	// COLON colon
+	COLON = ":"
 
 	// parentheses
 
@@ -54,6 +131,13 @@ const (
 	// RBRACE right brace
 	RBRACE = "}"
 
+	// bracket
+
+	// LBRACKET left bracket
+	LBRACKET = "["
+	// RBRACKET right bracket
+	RBRACKET = "]"
+
 	FUNCTION = "FUNCTION"
 
 	LET    = "LET"
@@ -62,16 +146,27 @@ const (
 	IF     = "IF"
 	ELSE   = "ELSE"
 	RETURN = "RETURN"
+
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
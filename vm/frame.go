@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"interpreter/code"
+	"interpreter/object"
+)
+
+/*
+Frame
+関数呼び出し一回分の実行状態
+ipは実行中の命令の位置、basePointerはローカル変数の始まるスタックの位置
+*/
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,463 @@
+package vm
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("%q - compiler error: %s", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("%q - vm error: %s", tt.input, err)
+		}
+		stackElem := vm.LastPoppedStackElem()
+		testExpectedObject(t, tt.input, tt.expected, stackElem)
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("%q - wrong integer. want=%d, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case float64:
+		float, ok := actual.(*object.Float)
+		if !ok || float.Value != expected {
+			t.Errorf("%q - wrong float. want=%g, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("%q - wrong boolean. want=%t, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case string:
+		str, ok := actual.(*object.String)
+		if !ok || str.Value != expected {
+			t.Errorf("%q - wrong string. want=%q, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%q - object is not Null. got=%T (%+v)", input, actual, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%q - wrong array. want=%v, got=%T (%+v)", input, expected, actual, actual)
+			return
+		}
+		for i, e := range expected {
+			testExpectedObject(t, input, e, array.Elements[i])
+		}
+	case map[object.HashKey]int64:
+		hash, ok := actual.(*object.Hash)
+		if !ok || len(hash.Pairs) != len(expected) {
+			t.Errorf("%q - wrong hash. got=%T (%+v)", input, actual, actual)
+			return
+		}
+		for k, v := range expected {
+			pair, ok := hash.Pairs[k]
+			if !ok {
+				t.Errorf("%q - no pair for given key in Pairs", input)
+				continue
+			}
+			testExpectedObject(t, input, int(v), pair.Value)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-10", -10},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 << 64", 0},
+		{"1 | 2 ^ 3 & 4", 3},
+		{"0xFF & 0b1010", 10},
+		{"0o755 >> 6", 7},
+		{"1_000 * 1_000", 1000000},
+		{"let x = 10; x = x % 4; x", 2},
+	}
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"3 * 0.5", 1.5},
+		{"1 - 0.25", 0.75},
+		{"7.5 % 2", 1.5},
+		{"2.0 ** 10", 1024.0},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { sum += x; }; sum * 1.0 / 4", 2.5},
+		{"let x = 1; x *= 1.5; x", 1.5},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1 < 1.5", true},
+		{"2.5 >= 3", false},
+		{"-0.5 <= -0.5", true},
+		{"!0.0", false},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"!true", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+	}
+	runVmTests(t, tests)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || false", false},
+		{"false || true", true},
+		{"true || false", true},
+		{`1 && ""`, true},
+		{"if (false) { 1 } || 0", true},
+		{"!5 || if (false) { 1 }", false},
+		{"1 < 2 && 2 < 3", true},
+		{"false && 1 + true", false},
+		{"true || 1 + true", true},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); true && f(); n == 1", true},
+		{"if (1 > 2 || 3 > 2) { 10 } else { 20 }", 10},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (false) { 10 }", Null},
+		{"if (true) { }", Null},
+		{"if (true) { let a = 1; }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let x = 1; let x = x + 1; x", 2},
+	}
+	runVmTests(t, tests)
+}
+
+func TestStringsArraysHashes(t *testing.T) {
+	tests := []vmTestCase{
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{"[1 + 2, 3 * 4]", []int{3, 12}},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[1, 2, 3][99]", Null},
+		{"[1][-1]", Null},
+		{"{1: 2, 2: 3}[1]", 2},
+		{"{1: 1}[0]", Null},
+		{`{"a": {"b": [10, 20]}}["a"]["b"][1]`, 20},
+		{
+			"{1 + 1: 2 * 2, 3 + 3: 4 * 4}",
+			map[object.HashKey]int64{
+				(&object.Integer{Value: 2}).HashKey(): 4,
+				(&object.Integer{Value: 6}).HashKey(): 16,
+			},
+		},
+	}
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let f = fn() { let x = if (true) { return 7; }; x + 1 }; f()", 7},
+		{"let f = fn() { [1, if (true) { return 3; }][0] + 10 }; f()", 3},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let letLast = fn() { let a = 1; }; letLast();", Null},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{
+			`let globalNum = 10;
+let sum = fn(a, b) { let c = a + b; c + globalNum; };
+let outer = fn() { sum(1, 2) + sum(3, 4) + globalNum; };
+outer() + globalNum;`,
+			50,
+		},
+		{"fn(x) { x }(5)", 5},
+	}
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();", 99},
+		{
+			`let newAdderOuter = fn(a, b) {
+  let c = a + b;
+  fn(d) {
+    let e = d + c;
+    fn(f) { e + f; };
+  };
+};
+let newAdderInner = newAdderOuter(1, 2);
+let adder = newAdderInner(3);
+adder(8);`,
+			14,
+		},
+		{
+			`let wrapper = fn() {
+  let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+  countDown(1);
+};
+wrapper();`,
+			0,
+		},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15);", 610},
+		{"let wrapper = fn() { let f = (fn(n) { if (n == 0) { 7 } else { f(n - 1) } }); f(3) }; wrapper();", 7},
+		{"let wrapper = fn() { let f = fn() { f = 5; f }; f(); f }; wrapper();", 5},
+		{"let f = fn() { f = 6; f }; f(); f", 6},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, Null},
+		{`push([], 1)`, []int{1}},
+		{`puts("hello")`, Null},
+	}
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"1 < true", "type mismatch: INTEGER < BOOLEAN"},
+		{"10 / 0", "division by zero"},
+		{"1[0]", "index operator not supported: INTEGER[INTEGER]"},
+		{"{[1]: 2}", "unusable as hash key: ARRAY"},
+		{"let x = 5; x(1);", "not a function: INTEGER"},
+		{"fn() { 1; }(1);", "wrong number of arguments: want=0, got=1"},
+		{"len(1)", "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { f() }; f();", "stack overflow"},
+		{"if (false) { let y = 1; }; y", "identifier not found: y"},
+		{"fn() { if (false) { let y = 1; }; y }()", "identifier not found: y"},
+		{"fn() { if (false) { let y = 1; }; fn() { y } }()()", "identifier not found: y"},
+		{`for (c in "abc") { c }`, "cannot iterate over STRING"},
+		{"let x = 1; x /= 0", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"1.5 % 0", "division by zero"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{`1.5 + "a"`, "type mismatch: FLOAT + STRING"},
+		{"{1.5: 1}", "unusable as hash key: FLOAT"},
+		{"2 ** -1", "negative exponent: -1"},
+		{"1 << -1", "negative shift count: -1"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"1 & true", "type mismatch: INTEGER & BOOLEAN"},
+		{"true >= false", "unknown operator: BOOLEAN >= BOOLEAN"},
+		{"true && 1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{`let a = [1]; a["x"] = 2`, "index assignment not supported: ARRAY[STRING]"},
+		{"let h = {}; h[[1]] = 2", "unusable as hash key: ARRAY"},
+		{`let h = {}; h["n"] += 1`, "type mismatch: NULL + INTEGER"},
+	}
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("%q - compiler error: %s", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Errorf("%q - expected VM error but resulted in none", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q - wrong VM error. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestPutsUsesOutput(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`puts("a", 1)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	var out bytes.Buffer
+	vm := New(comp.Bytecode())
+	vm.SetOutput(&out)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if out.String() != "a\n1\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+const fibonacciInput = `
+let fibonacci = fn(x) {
+  if (x == 0) {
+    0
+  } else {
+    if (x == 1) {
+      return 1;
+    } else {
+      fibonacci(x - 1) + fibonacci(x - 2);
+    }
+  }
+};
+fibonacci(20);
+`
+
+func BenchmarkFibonacci(b *testing.B) {
+	program := parse(fibonacciInput)
+	for i := 0; i < b.N; i++ {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } let s = s + x; }; s", 8},
+		{"let s = 0; for (i in 5) { let s = s + i; }; s", 10},
+		{`let s = ""; for (k in {"b": 1, "c": 2, "a": 3}) { let s = s + k; }; s`, "abc"},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f()", 20},
+		{"let f = fn(n) { let s = 0; while (n > 0) { let s = s + n; let n = n - 1; } s }; f(4)", 10},
+		{"let n = 0; for (i in 3) { for (j in 3) { if (j == 1) { break; } let n = n + 1; } }; n", 3},
+		{"let f = fn() { let n = 0; for (i in 3) { for (j in 3) { let n = n + 1; } } n }; f()", 9},
+		{"let n = 0; while (n < 3) { let x = if (true) { break; }; let n = n + 1; }; n", 0},
+		{"let s = 0; for (i in 4) { let s = s + if (i == 1) { continue; } else { i }; }; s", 5},
+		{"let s = 0; for (i in 4) { s += if (i == 1) { continue; } else { i }; }; s", 5},
+		{"let n = 0; for (i in 3) { len([1, if (true) { break; }]); let n = n + 1; }; n", 0},
+		{"let n = 0; while (n < 3) { let n = n + 1; if (n > 1) { break; } n + if (true) { continue; }; }; n", 2},
+		{"let n = 0; while (n < 3) { n += 1; if (n > 1 || if (true) { continue; }) { break; } }; n", 2},
+		{"let i = 0; while (i < 100000) { let i = i + 1; }; i", 100000},
+		{"let f = fn() { while (true) { break; } }; f()", Null},
+		// 式の途中のbreakとcontinueが積んだ値を残すと、StackSizeを超える回数でスタックがあふれる
+		{"let i = 0; while (i < 5000) { let i = i + 1; let a = [1, if (true) { continue; }]; }; i", 5000},
+		{"let s = 0; for (i in 5000) { let s = s + if (i / 2 * 2 == i) { continue; } else { 1 }; }; s", 2500},
+		{"let n = 0; for (i in 5000) { let n = n + 1; puts(1, {2: if (true) { continue; }}); }; n", 5000},
+		{"let f = fn() { let i = 0; while (i < 5000) { let i = i + 1; 1 + if (true) { continue; }; } i }; f()", 5000},
+		{"let f = fn() { let a = [0]; let i = 0; while (i < 5000) { i += 1; a[0] += 1 + if (true) { continue; }; } i }; f()", 5000},
+		{"let n = 0; for (i in 5000) { for (j in 1) { let n = n + [1][if (true) { break; }]; } }; n", 0},
+	}
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let a = 0; let b = 0; a = b = 3; a + b", 6},
+		{"let x = 1; let f = fn() { x = 5; }; f(); x", 5},
+		{"let x = 1; let f = fn(x) { x = 5; x }; f(0) * 10 + x", 51},
+		{"let f = fn() { let y = 1; y += 2; y }; f()", 3},
+		{"let counter = fn() { let c = 0; fn() { c += 1; c } }; let next = counter(); next(); next(); next()", 3},
+		{"let f = fn() { let x = 0; let inc = fn() { x += 1 }; inc(); x }; f()", 1},
+		{"let make = fn() { let n = 0; [fn() { n += 1 }, fn() { n }] }; let p = make(); p[0](); p[0](); p[1]()", 2},
+		{"let f = fn() { let x = 1; let g = fn() { fn() { x = 5 } }; g()(); x }; f()", 5},
+		{"let f = fn(x) { let set = fn() { x = 9 }; set(); x }; f(1)", 9},
+		{"let f = fn() { let x = 0; fn() { x } }; let g = f(); let h = fn() { let y = 7; y }; h(); g()", 0},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[1] + arr[2]", 23},
+		{"let arr = [1, 2, 3]; arr[0] += 9; arr[0]", 10},
+		{`let h = {"a": 1}; h["a"] *= 7; h["b"] = 2; h["a"] + h["b"]`, 9},
+		{"let m = [[1], [2]]; m[1][0] = 5; m[1][0]", 5},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; }; sum", 6},
+		{"let f = fn(n) { let i = 0; while (i < n) { i += 1; } i }; f(5)", 5},
+	}
+	runVmTests(t, tests)
+}